@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a size bounded cache whose entries also expire after a fixed TTL.
+// It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[K]*list.Element
+	order    *list.List
+	hits     uint64
+	misses   uint64
+	now      func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+type Stats struct {
+	Size     int    `json:"size"`
+	Capacity int    `json:"capacity"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+}
+
+func NewLRU[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	var zero V
+	element, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return zero, false
+	}
+
+	item := element.Value.(*entry[K, V])
+	if c.ttl > 0 && c.now().After(item.expiresAt) {
+		c.removeElement(element)
+		c.misses++
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits++
+	return item.value, true
+}
+
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.Lock()
+	defer c.Unlock()
+
+	expiresAt := c.now().Add(c.ttl)
+	if element, ok := c.items[key]; ok {
+		item := element.Value.(*entry[K, V])
+		item.value = value
+		item.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *LRU[K, V]) Delete(key K) {
+	c.Lock()
+	defer c.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *LRU[K, V]) Purge() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+}
+
+func (c *LRU[K, V]) Stats() Stats {
+	c.Lock()
+	defer c.Unlock()
+
+	return Stats{
+		Size:     c.order.Len(),
+		Capacity: c.capacity,
+		Hits:     c.hits,
+		Misses:   c.misses,
+	}
+}
+
+func (c *LRU[K, V]) removeElement(element *list.Element) {
+	item := element.Value.(*entry[K, V])
+	delete(c.items, item.key)
+	c.order.Remove(element)
+}
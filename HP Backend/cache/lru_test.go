@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock replaces the cache's clock so tests can move time forward.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLRU(capacity int, ttl time.Duration) (*LRU[string, int], *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	lru := NewLRU[string, int](capacity, ttl)
+	lru.now = clock.Now
+	return lru, clock
+}
+
+func TestLRUExpiresEntriesAfterTTL(t *testing.T) {
+	lru, clock := newTestLRU(10, time.Minute)
+	lru.Set("a", 1)
+
+	clock.now = clock.now.Add(time.Minute)
+	if value, ok := lru.Get("a"); !ok || value != 1 {
+		t.Fatalf("Get at the TTL = %d, %v, want 1, true", value, ok)
+	}
+
+	clock.now = clock.now.Add(time.Nanosecond)
+	if _, ok := lru.Get("a"); ok {
+		t.Fatal("Get after the TTL found the entry")
+	}
+	if size := lru.Stats().Size; size != 0 {
+		t.Errorf("expired entry is still counted, size = %d", size)
+	}
+}
+
+func TestLRUSetRefreshesTTL(t *testing.T) {
+	lru, clock := newTestLRU(10, time.Minute)
+	lru.Set("a", 1)
+
+	clock.now = clock.now.Add(50 * time.Second)
+	lru.Set("a", 2)
+	clock.now = clock.now.Add(50 * time.Second)
+
+	if value, ok := lru.Get("a"); !ok || value != 2 {
+		t.Fatalf("Get = %d, %v, want 2, true", value, ok)
+	}
+}
+
+func TestLRUWithoutTTLNeverExpires(t *testing.T) {
+	lru, clock := newTestLRU(10, 0)
+	lru.Set("a", 1)
+
+	clock.now = clock.now.Add(24 * 365 * time.Hour)
+	if _, ok := lru.Get("a"); !ok {
+		t.Fatal("entry expired without a TTL")
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	tests := []struct {
+		name    string
+		touch   func(*LRU[string, int])
+		evicted string
+	}{
+		{name: "oldest entry", touch: func(*LRU[string, int]) {}, evicted: "a"},
+		{name: "Get keeps an entry", touch: func(l *LRU[string, int]) { l.Get("a") }, evicted: "b"},
+		{name: "Set keeps an entry", touch: func(l *LRU[string, int]) { l.Set("a", 10) }, evicted: "b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lru, _ := newTestLRU(3, time.Minute)
+			lru.Set("a", 1)
+			lru.Set("b", 2)
+			lru.Set("c", 3)
+			test.touch(lru)
+			lru.Set("d", 4)
+
+			if _, ok := lru.Get(test.evicted); ok {
+				t.Errorf("%s was kept", test.evicted)
+			}
+			if size := lru.Stats().Size; size != 3 {
+				t.Errorf("size = %d, want 3", size)
+			}
+		})
+	}
+}
+
+func TestLRUCapacityIsAtLeastOne(t *testing.T) {
+	lru, _ := newTestLRU(0, time.Minute)
+	lru.Set("a", 1)
+	lru.Set("b", 2)
+
+	if _, ok := lru.Get("b"); !ok {
+		t.Fatal("latest entry was evicted")
+	}
+	if stats := lru.Stats(); stats.Size != 1 || stats.Capacity != 1 {
+		t.Errorf("stats = %+v, want size and capacity 1", stats)
+	}
+}
+
+func TestLRUDeleteAndPurge(t *testing.T) {
+	lru, _ := newTestLRU(10, time.Minute)
+	lru.Set("a", 1)
+	lru.Set("b", 2)
+	lru.Set("c", 3)
+
+	lru.Delete("a")
+	lru.Delete("missing")
+	if _, ok := lru.Get("a"); ok {
+		t.Error("deleted entry was found")
+	}
+
+	lru.Purge()
+	if size := lru.Stats().Size; size != 0 {
+		t.Errorf("size after Purge = %d, want 0", size)
+	}
+	lru.Set("d", 4)
+	if _, ok := lru.Get("d"); !ok {
+		t.Error("cache unusable after Purge")
+	}
+}
+
+func TestLRUCountsHitsAndMisses(t *testing.T) {
+	lru, clock := newTestLRU(10, time.Minute)
+	lru.Set("a", 1)
+
+	lru.Get("a")
+	lru.Get("a")
+	lru.Get("missing")
+	clock.now = clock.now.Add(2 * time.Minute)
+	lru.Get("a")
+
+	if stats := lru.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("hits = %d, misses = %d, want 2 and 2", stats.Hits, stats.Misses)
+	}
+}
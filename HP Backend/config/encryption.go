@@ -0,0 +1,111 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+
+	"houseparty.com/secrets"
+	"houseparty.com/storage"
+)
+
+var tokenKeys *secrets.Keyring
+
+func InitTokenEncryption() error {
+	keyring, err := secrets.ParseKeyring(os.Getenv("TOKEN_ENCRYPTION_KEYS"))
+	if err != nil {
+		return err
+	}
+	if keyring == nil {
+		slog.Warn("TOKEN_ENCRYPTION_KEYS is not set, spotify tokens are stored in plaintext")
+	}
+
+	tokenKeys = keyring
+	return nil
+}
+
+type storedToken struct {
+	id           int64
+	accessToken  string
+	refreshToken string
+}
+
+// ReencryptTokens seals every token row with the primary key. It encrypts rows
+// written before encryption was enabled and re-wraps rows sealed with a
+// retired key, returning how many rows were rewritten. A row that changed
+// after it was read was saved by a refresh with the primary key already, so
+// it is skipped rather than overwritten with the older token.
+func ReencryptTokens() (int, error) {
+	if tokenKeys == nil {
+		return 0, nil
+	}
+
+	rows, err := storage.DB.Query(storage.AllTokenSecretsQuery)
+	if err != nil {
+		return 0, err
+	}
+
+	var tokens []storedToken
+	for rows.Next() {
+		var token storedToken
+		if err := rows.Scan(&token.id, &token.accessToken, &token.refreshToken); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if tokenKeys.NeedsRotation(token.accessToken) || tokenKeys.NeedsRotation(token.refreshToken) {
+			tokens = append(tokens, token)
+		}
+	}
+	rows.Close()
+
+	rewritten := 0
+	for _, token := range tokens {
+		accessToken, refreshToken, err := decryptTokenPair(token.accessToken, token.refreshToken)
+		if err != nil {
+			return rewritten, err
+		}
+		accessToken, refreshToken, err = encryptTokenPair(accessToken, refreshToken)
+		if err != nil {
+			return rewritten, err
+		}
+
+		result, err := storage.DB.Exec(storage.UpdateTokenSecretsQuery, accessToken, refreshToken, token.id, token.accessToken, token.refreshToken)
+		if err != nil {
+			return rewritten, err
+		}
+		changed, err := result.RowsAffected()
+		if err != nil {
+			return rewritten, err
+		}
+		if changed == 0 {
+			slog.Info("token changed while re-encrypting, skipping", "token_id", token.id)
+			continue
+		}
+		rewritten++
+	}
+
+	return rewritten, nil
+}
+
+func encryptTokenPair(accessToken, refreshToken string) (string, string, error) {
+	accessToken, err := tokenKeys.Encrypt(accessToken)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err = tokenKeys.Encrypt(refreshToken)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+func decryptTokenPair(accessToken, refreshToken string) (string, string, error) {
+	accessToken, err := tokenKeys.Decrypt(accessToken)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err = tokenKeys.Decrypt(refreshToken)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
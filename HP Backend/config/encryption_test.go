@@ -0,0 +1,97 @@
+package config
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"houseparty.com/secrets"
+	"houseparty.com/storage"
+)
+
+func useTokenKeys(t *testing.T, ids ...string) {
+	t.Helper()
+	var entries []string
+	for _, id := range ids {
+		entries = append(entries, id+":"+base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id[:1], 32))))
+	}
+	t.Setenv("TOKEN_ENCRYPTION_KEYS", strings.Join(entries, ","))
+	if err := InitTokenEncryption(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tokenKeys = nil })
+}
+
+func storedSecrets(t *testing.T, userId int64) (int64, string, string) {
+	t.Helper()
+	var id int64
+	var accessToken, refreshToken string
+	err := storage.DB.QueryRow(`SELECT id, access_token, refresh_token FROM token WHERE user_id = ?`, userId).Scan(&id, &accessToken, &refreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, accessToken, refreshToken
+}
+
+func TestReencryptTokensRewrapsRetiredKeys(t *testing.T) {
+	openTestDB(t)
+	saveTestToken(t, 1)
+	useTokenKeys(t, "old")
+	saveTestToken(t, 2)
+	useTokenKeys(t, "new", "old")
+
+	count, err := ReencryptTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("rewrote %d tokens, want 2", count)
+	}
+
+	for _, userId := range []int64{1, 2} {
+		_, accessToken, refreshToken := storedSecrets(t, userId)
+		if !secrets.IsEncrypted(accessToken) || tokenKeys.NeedsRotation(accessToken) || tokenKeys.NeedsRotation(refreshToken) {
+			t.Errorf("user %d token is not sealed with the primary key", userId)
+		}
+		token, err := GetTokenFromDB(userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.AccessToken != "old" || token.RefreshToken != "refresh" {
+			t.Errorf("user %d token = %q/%q after re-encrypting", userId, token.AccessToken, token.RefreshToken)
+		}
+	}
+
+	if count, err := ReencryptTokens(); err != nil || count != 0 {
+		t.Errorf("second run rewrote %d tokens (%v), want 0", count, err)
+	}
+}
+
+func TestUpdateTokenSecretsKeepsNewerToken(t *testing.T) {
+	openTestDB(t)
+	useTokenKeys(t, "new")
+	saveTestToken(t, 1)
+	id, staleAccess, staleRefresh := storedSecrets(t, 1)
+
+	// A refresh lands between reading the row and writing it back.
+	refreshed := SpotifyTokenObject{AccessToken: "fresh", TokenType: "Bearer", ExpiresIn: 3600, RefreshToken: "refresh", UserID: 1}
+	if err := refreshed.UpdateToken(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := storage.DB.Exec(storage.UpdateTokenSecretsQuery, "rewrapped", "rewrapped", id, staleAccess, staleRefresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := result.RowsAffected(); changed != 0 {
+		t.Errorf("update with stale ciphertext changed %d rows, want 0", changed)
+	}
+
+	token, err := GetTokenFromDB(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "fresh" {
+		t.Errorf("access token = %q, want the refreshed one", token.AccessToken)
+	}
+}
@@ -1,16 +1,20 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"houseparty.com/mail"
 )
 
 func LoadEnv() {
 	err := godotenv.Load()
 	if err != nil {
-		log.Println("no .env file loaded")
+		slog.Info("no .env file loaded")
 	}
 }
 
@@ -18,6 +22,151 @@ func GetJWTKey() string {
 	return os.Getenv("SECRET_JWT_KEY")
 }
 
+func GetLogLevel() string {
+	return os.Getenv("LOG_LEVEL")
+}
+
+func GetLogFormat() string {
+	return os.Getenv("LOG_FORMAT")
+}
+
+func GetTraceExporter() string {
+	return os.Getenv("OTEL_TRACES_EXPORTER")
+}
+
+func GetSpotifyAPIURL() string {
+	return getEnvOrDefault("SPOTIFY_API_URL", "https://api.spotify.com/v1")
+}
+
+func GetSpotifyAccountsURL() string {
+	return getEnvOrDefault("SPOTIFY_ACCOUNTS_URL", "https://accounts.spotify.com")
+}
+
+func GetSpotifyMarket() string {
+	return os.Getenv("SPOTIFY_MARKET")
+}
+
+func GetSearchCacheSize() int {
+	size, err := strconv.Atoi(os.Getenv("SEARCH_CACHE_SIZE"))
+	if err != nil || size <= 0 {
+		return 512
+	}
+	return size
+}
+
+func GetSearchCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("SEARCH_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		return 10 * time.Minute
+	}
+	return ttl
+}
+
+func GetFakeProviderEnabled() bool {
+	return os.Getenv("FAKE_MUSIC_PROVIDER") == "true"
+}
+
+func GetLibraryDir() string {
+	return getEnvOrDefault("LOCAL_LIBRARY_DIR", "media")
+}
+
+// GetLibraryMaxUpload is the largest audio file the local library accepts.
+func GetLibraryMaxUpload() int64 {
+	megabytes, err := strconv.ParseInt(os.Getenv("LOCAL_LIBRARY_MAX_UPLOAD_MB"), 10, 64)
+	if err != nil || megabytes <= 0 {
+		return 100 << 20
+	}
+	return megabytes << 20
+}
+
+// GetLibraryQuota is how many bytes of audio one user can keep in the local
+// library.
+func GetLibraryQuota() int64 {
+	megabytes, err := strconv.ParseInt(os.Getenv("LOCAL_LIBRARY_QUOTA_MB"), 10, 64)
+	if err != nil || megabytes <= 0 {
+		return 1 << 30
+	}
+	return megabytes << 20
+}
+
+// GetAccessTokenTTL is how long a login JWT stays valid before the client
+// has to use its refresh token.
+func GetAccessTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return 15 * time.Minute
+	}
+	return ttl
+}
+
+func GetRefreshTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return ttl
+}
+
+// GetMediaURLTTL is how long signed links to local library audio and covers
+// keep working. Queued songs carry their links, so it has to outlast a party.
+func GetMediaURLTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("MEDIA_URL_TTL"))
+	if err != nil || ttl <= 0 {
+		return 24 * time.Hour
+	}
+	return ttl
+}
+
+// GetMailConfig reads the SMTP settings. Without SMTP_HOST, MAIL_DEV=true is
+// required and mail is only logged, and saved to MAIL_DIR when that is set.
+func GetMailConfig() mail.Config {
+	return mail.Config{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     getEnvOrDefault("SMTP_PORT", "587"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     getEnvOrDefault("MAIL_FROM", "House Party <no-reply@houseparty.local>"),
+		Dir:      os.Getenv("MAIL_DIR"),
+		Dev:      os.Getenv("MAIL_DEV") == "true",
+		Timeout:  getMailTimeout(),
+	}
+}
+
+func getMailTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("SMTP_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return 10 * time.Second
+	}
+	return timeout
+}
+
+// GetTrustedProxies lists the proxies allowed to set X-Forwarded-For. Client
+// addresses are used for login throttling, so no proxy is trusted by default.
+func GetTrustedProxies() []string {
+	proxies := strings.Split(os.Getenv("TRUSTED_PROXIES"), ",")
+	trusted := proxies[:0]
+	for _, proxy := range proxies {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trusted = append(trusted, proxy)
+		}
+	}
+	return trusted
+}
+
+// GetMetricsAddr is where Prometheus metrics are served. It defaults to
+// loopback so they are not public unless the deployment opts in.
+func GetMetricsAddr() string {
+	return getEnvOrDefault("METRICS_ADDR", "127.0.0.1:9090")
+}
+
+func getEnvOrDefault(key, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
 func GetFrontendURL() string {
 	url := os.Getenv("FRONTEND_URL")
 
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"houseparty.com/storage"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+// LoginStateUserID is stored with the states of "Log in with Spotify", where
+// nobody is signed in yet. No account has this id, so the two flows cannot
+// redeem each other's states.
+const LoginStateUserID int64 = 0
+
+var ErrInvalidOAuthState = errors.New("spotify authorization state is invalid or has expired")
+
+type OAuthState struct {
+	State         string
+	CodeVerifier  string
+	CodeChallenge string
+	UserID        int64
+	ExpiresAt     int64
+}
+
+// NewOAuthState creates and stores a single-use state bound to the user,
+// together with a PKCE verifier whose S256 challenge goes in the auth URL.
+func NewOAuthState(userId int64) (*OAuthState, error) {
+	state, err := randomURLString(24)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomURLString(48)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	oauthState := &OAuthState{
+		State:         state,
+		CodeVerifier:  verifier,
+		CodeChallenge: base64.RawURLEncoding.EncodeToString(challenge[:]),
+		UserID:        userId,
+		ExpiresAt:     time.Now().Add(oauthStateTTL).Unix(),
+	}
+
+	_, err = storage.DB.Exec(storage.DeleteExpiredOAuthStatesQuery, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = storage.DB.Exec(storage.SaveOAuthStateQuery, oauthState.State, oauthState.UserID, oauthState.CodeVerifier, oauthState.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return oauthState, nil
+}
+
+// ConsumeOAuthState deletes the state and returns its PKCE verifier if it was
+// issued to userId and has not expired.
+func ConsumeOAuthState(state string, userId int64) (string, error) {
+	if state == "" {
+		return "", ErrInvalidOAuthState
+	}
+
+	var storedUserId, expiresAt int64
+	var verifier string
+	err := storage.DB.QueryRow(storage.ConsumeOAuthStateQuery, state).Scan(&storedUserId, &verifier, &expiresAt)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidOAuthState
+	} else if err != nil {
+		return "", err
+	}
+
+	if storedUserId != userId || time.Now().Unix() > expiresAt {
+		return "", ErrInvalidOAuthState
+	}
+
+	return verifier, nil
+}
+
+func randomURLString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
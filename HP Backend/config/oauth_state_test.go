@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"houseparty.com/storage"
+)
+
+func TestConsumeOAuthStateOnlyOnce(t *testing.T) {
+	openTestDB(t)
+	state, err := NewOAuthState(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const callbacks = 20
+	var wg sync.WaitGroup
+	verifiers := make([]string, callbacks)
+	errs := make([]error, callbacks)
+	for i := range callbacks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			verifiers[i], errs[i] = ConsumeOAuthState(state.State, 1)
+		}()
+	}
+	wg.Wait()
+
+	redeemed := 0
+	for i := range callbacks {
+		switch {
+		case errs[i] == nil:
+			redeemed++
+			if verifiers[i] != state.CodeVerifier {
+				t.Errorf("callback %d got verifier %q, want %q", i, verifiers[i], state.CodeVerifier)
+			}
+		case !errors.Is(errs[i], ErrInvalidOAuthState):
+			t.Fatalf("callback %d: %v", i, errs[i])
+		}
+	}
+	if redeemed != 1 {
+		t.Errorf("state redeemed %d times, want once", redeemed)
+	}
+}
+
+func TestConsumeOAuthStateRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		userId int64
+		expire bool
+	}{
+		{name: "another user", userId: 2},
+		{name: "the login flow", userId: LoginStateUserID},
+		{name: "an expired state", userId: 1, expire: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			openTestDB(t)
+			state, err := NewOAuthState(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expire {
+				_, err := storage.DB.Exec(`UPDATE oauth_states SET expires_at = ? WHERE state = ?`, time.Now().Add(-time.Minute).Unix(), state.State)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := ConsumeOAuthState(state.State, test.userId); !errors.Is(err, ErrInvalidOAuthState) {
+				t.Fatalf("error = %v, want %v", err, ErrInvalidOAuthState)
+			}
+			// A rejected state is still spent, so the right user cannot
+			// retry with it either.
+			if _, err := ConsumeOAuthState(state.State, 1); !errors.Is(err, ErrInvalidOAuthState) {
+				t.Errorf("second attempt = %v, want %v", err, ErrInvalidOAuthState)
+			}
+		})
+	}
+}
@@ -1,21 +1,34 @@
 package config
 
 import (
-	"crypto/rand"
+	"context"
 	"database/sql"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"houseparty.com/httpclient"
+	"houseparty.com/metrics"
 	"houseparty.com/storage"
+	"houseparty.com/tracing"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+const (
+	ScopePlaylistModifyPrivate = "playlist-modify-private"
+	ScopePlaylistModifyPublic  = "playlist-modify-public"
 )
 
 type SpotifyTokenObject struct {
@@ -28,6 +41,21 @@ type SpotifyTokenObject struct {
 	UserID       int64  `json:"user_id"`
 }
 
+type TokenRequestError struct {
+	Status  int
+	Message string
+}
+
+func (e *TokenRequestError) Error() string {
+	return fmt.Sprintf("spotify token request failed with status %d: %s", e.Status, e.Message)
+}
+
+// Revoked reports whether Spotify rejected the refresh token itself, which
+// happens when the user removes the app from their account.
+func (e *TokenRequestError) Revoked() bool {
+	return e.Status == http.StatusBadRequest && strings.Contains(e.Message, "invalid_grant")
+}
+
 func (s *SpotifyTokenObject) SaveToken() error {
 
 	deleteStmt, err := storage.DB.Prepare(storage.DeleteTokenQuery)
@@ -47,7 +75,12 @@ func (s *SpotifyTokenObject) SaveToken() error {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(s.AccessToken, s.TokenType, s.Scope, s.ExpiresIn, s.RefreshToken, s.TimeIssued, s.UserID)
+	accessToken, refreshToken, err := encryptTokenPair(s.AccessToken, s.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(accessToken, s.TokenType, s.Scope, s.ExpiresIn, refreshToken, s.TimeIssued, s.UserID)
 
 	if err != nil {
 		return err
@@ -60,8 +93,14 @@ func (s *SpotifyTokenObject) UpdateToken() error {
 	if err != nil {
 		return err
 	}
+	defer stmt.Close()
+
+	accessToken, refreshToken, err := encryptTokenPair(s.AccessToken, s.RefreshToken)
+	if err != nil {
+		return err
+	}
 
-	_, err = stmt.Exec(s.AccessToken, s.TokenType, s.Scope, s.ExpiresIn, s.TimeIssued, s.UserID)
+	_, err = stmt.Exec(accessToken, s.TokenType, s.Scope, s.ExpiresIn, refreshToken, s.TimeIssued, s.UserID)
 	if err != nil {
 		return err
 	}
@@ -75,11 +114,16 @@ func GetTokenFromDB(hostId int64) (*SpotifyTokenObject, error) {
 	err := row.Scan(&token.AccessToken, &token.TokenType, &token.Scope, &token.ExpiresIn, &token.RefreshToken, &token.TimeIssued, &token.UserID)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("token not found")
+		return nil, ErrTokenNotFound
 	} else if err != nil {
 		return nil, err
 	}
 
+	token.AccessToken, token.RefreshToken, err = decryptTokenPair(token.AccessToken, token.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
 	return &token, nil
 }
 
@@ -87,16 +131,24 @@ func checkIfTokenExpired(token *SpotifyTokenObject) bool {
 	return token.TimeIssued+token.ExpiresIn < int((int64)(time.Now().Unix()))
 }
 
-func GetSpotifyTokenObject(hostId int64) (*SpotifyTokenObject, error) {
+func GetSpotifyTokenObject(ctx context.Context, hostId int64) (*SpotifyTokenObject, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "config.GetSpotifyTokenObject")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("user.id", hostId))
+
 	token, err := GetTokenFromDB(hostId)
 	if err != nil {
-		log.Println("Error with getting from db")
+		slog.Error("could not load spotify token", "user_id", hostId, "error", err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
-	if checkIfTokenExpired(token) {
-		tokenRefresh, err := RefreshToken(token.RefreshToken, token.UserID)
+	expired := checkIfTokenExpired(token)
+	span.SetAttributes(attribute.Bool("token.expired", expired))
+	if expired {
+		tokenRefresh, err := Tokens.Refresh(ctx, token.UserID)
 		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
 			return nil, err
 		}
 		token = tokenRefresh
@@ -106,14 +158,28 @@ func GetSpotifyTokenObject(hostId int64) (*SpotifyTokenObject, error) {
 
 }
 
-func SetSpotifyToken(code string, userId int64) (*SpotifyTokenObject, error) {
+func SetSpotifyToken(ctx context.Context, code, codeVerifier string, userId int64) (*SpotifyTokenObject, error) {
+	tokenObject, err := ExchangeSpotifyCode(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	tokenObject.UserID = userId
+
+	err = tokenObject.SaveToken()
+	if err != nil {
+		return nil, err
+	}
 
-	log.Println("Code Here: " + code)
+	return tokenObject, nil
+}
 
+// ExchangeSpotifyCode trades an authorization code for tokens without saving
+// them, for callers that only learn which user they belong to afterwards.
+func ExchangeSpotifyCode(ctx context.Context, code, codeVerifier string) (*SpotifyTokenObject, error) {
 	clientID := os.Getenv("SPOTIFY_CLIENT_ID")
 	clientSecret := os.Getenv("SPOTIFY_CLIENT_SECRET")
 	if clientID == "" || clientSecret == "" {
-		return nil, errors.New("missing spotify client id or client secret" + clientID + clientSecret)
+		return nil, errors.New("missing spotify client id or client secret")
 	}
 
 	redirectUrl := GetFrontendURL()
@@ -122,9 +188,10 @@ func SetSpotifyToken(code string, userId int64) (*SpotifyTokenObject, error) {
 	data.Set("code", code)
 	data.Add("redirect_uri", redirectUrl)
 	data.Add("grant_type", "authorization_code")
+	data.Add("client_id", clientID)
+	data.Add("code_verifier", codeVerifier)
 
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", "https://accounts.spotify.com/api/token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", GetSpotifyAccountsURL()+"/api/token", strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +201,7 @@ func SetSpotifyToken(code string, userId int64) (*SpotifyTokenObject, error) {
 	req.Header.Add("Authorization", "Basic "+encodedCredentials)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := client.Do(req)
+	resp, err := httpclient.Shared.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -142,7 +209,7 @@ func SetSpotifyToken(code string, userId int64) (*SpotifyTokenObject, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		return nil, errors.New(string(bodyBytes))
+		return nil, &TokenRequestError{Status: resp.StatusCode, Message: string(bodyBytes)}
 	}
 
 	var tokenObject SpotifyTokenObject
@@ -150,28 +217,31 @@ func SetSpotifyToken(code string, userId int64) (*SpotifyTokenObject, error) {
 		return nil, err
 	}
 	tokenObject.TimeIssued = int((int64)(time.Now().Unix()))
-	tokenObject.UserID = userId
-
-	err = tokenObject.SaveToken()
-	if err != nil {
-		return nil, err
-	}
 
 	return &tokenObject, nil
 }
 
-func GenerateSpotifyAuthRequest() (string, error) {
-	redirectUrl := GetFrontendURL()
+// HasScope reports whether the user granted scope when connecting. Accounts
+// connected before a scope was added have to reconnect to get it.
+func (s *SpotifyTokenObject) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(s.Scope), scope)
+}
 
-	log.Println(redirectUrl)
+func GenerateSpotifyAuthRequest(userId int64) (string, error) {
+	redirectUrl := GetFrontendURL()
 
-	scope := "streaming user-read-email user-read-private user-modify-playback-state user-read-playback-state"
+	scope := "streaming user-read-email user-read-private user-modify-playback-state user-read-playback-state " +
+		ScopePlaylistModifyPrivate + " " + ScopePlaylistModifyPublic
 
-	state := generateRandomString(16)
 	clientID := os.Getenv("SPOTIFY_CLIENT_ID")
 
 	if clientID == "" {
-		return "", errors.New("missing spotify client id or client secret" + clientID)
+		return "", errors.New("missing spotify client id")
+	}
+
+	state, err := NewOAuthState(userId)
+	if err != nil {
+		return "", err
 	}
 
 	data := url.Values{}
@@ -179,34 +249,30 @@ func GenerateSpotifyAuthRequest() (string, error) {
 	data.Add("client_id", clientID)
 	data.Add("scope", scope)
 	data.Add("redirect_uri", redirectUrl)
-	data.Add("state", state)
+	data.Add("state", state.State)
+	data.Add("code_challenge_method", "S256")
+	data.Add("code_challenge", state.CodeChallenge)
 
-	authUrl := "https://accounts.spotify.com/authorize/?" + data.Encode()
+	authUrl := GetSpotifyAccountsURL() + "/authorize/?" + data.Encode()
 
 	return authUrl, nil
 }
 
-func generateRandomString(n int) string {
-	b := make([]byte, n)
-	if _, err := rand.Read(b); err != nil {
-		log.Fatal("Error generating random string: ", err)
-	}
-	return hex.EncodeToString(b)[:n]
-}
+func RefreshToken(ctx context.Context, refreshToken string, userId int64) (*SpotifyTokenObject, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "config.RefreshToken")
+	defer span.End()
 
-func RefreshToken(refreshToken string, userId int64) (*SpotifyTokenObject, error) {
 	clientID := os.Getenv("SPOTIFY_CLIENT_ID")
 	clientSecret := os.Getenv("SPOTIFY_CLIENT_SECRET")
 	if clientID == "" || clientSecret == "" {
-		return nil, errors.New("missing spotify client id or client secret" + clientID + clientSecret)
+		return nil, errors.New("missing spotify client id or client secret")
 	}
 
 	data := url.Values{}
 	data.Add("refresh_token", refreshToken)
 	data.Add("grant_type", "refresh_token")
 
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", "https://accounts.spotify.com/api/token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", GetSpotifyAccountsURL()+"/api/token", strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -216,28 +282,42 @@ func RefreshToken(refreshToken string, userId int64) (*SpotifyTokenObject, error
 	req.Header.Add("Authorization", "Basic "+encodedCredentials)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := httpclient.Shared.Do(req)
+	metrics.ObserveSpotifyCall("RefreshToken", start, resp)
 	if err != nil {
+		metrics.TokenRefreshes.WithLabelValues("error").Inc()
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		return nil, errors.New(string(bodyBytes))
+		tokenError := &TokenRequestError{Status: resp.StatusCode, Message: string(bodyBytes)}
+		if tokenError.Revoked() {
+			metrics.TokenRefreshes.WithLabelValues("revoked").Inc()
+		} else {
+			metrics.TokenRefreshes.WithLabelValues("rejected").Inc()
+		}
+		return nil, tokenError
 	}
 
 	var tokenObject SpotifyTokenObject
 	if err := json.NewDecoder(resp.Body).Decode(&tokenObject); err != nil {
+		metrics.TokenRefreshes.WithLabelValues("error").Inc()
 		return nil, err
 	}
-	log.Println(resp.Body)
 	tokenObject.TimeIssued = int((int64)(time.Now().Unix()))
 	tokenObject.UserID = userId
+	if tokenObject.RefreshToken == "" {
+		tokenObject.RefreshToken = refreshToken
+	}
 
 	err = tokenObject.UpdateToken()
 	if err != nil {
+		metrics.TokenRefreshes.WithLabelValues("error").Inc()
 		return nil, err
 	}
+	metrics.TokenRefreshes.WithLabelValues("success").Inc()
 	return &tokenObject, nil
 }
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"houseparty.com/storage"
+)
+
+type refreshCall struct {
+	done  chan struct{}
+	token *SpotifyTokenObject
+	err   error
+}
+
+// TokenManager refreshes Spotify tokens ahead of expiry and makes sure only
+// one refresh per user is in flight; concurrent callers share its result.
+// When ActiveUsers is set, only those users are refreshed in the background;
+// everyone else is refreshed lazily on their next request.
+type TokenManager struct {
+	sync.Mutex
+	RefreshAhead time.Duration
+	Interval     time.Duration
+	ActiveUsers  func() []int64
+	OnRevoked    func(userId int64)
+	inflight     map[int64]*refreshCall
+}
+
+var Tokens = NewTokenManager()
+
+func NewTokenManager() *TokenManager {
+	return &TokenManager{
+		RefreshAhead: 5 * time.Minute,
+		Interval:     time.Minute,
+		inflight:     make(map[int64]*refreshCall),
+	}
+}
+
+func (m *TokenManager) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(m.Interval)
+		defer ticker.Stop()
+
+		for {
+			m.refreshExpiring(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (m *TokenManager) refreshExpiring(ctx context.Context) {
+	cutoff := time.Now().Add(m.RefreshAhead).Unix()
+	rows, err := storage.DB.QueryContext(ctx, storage.ExpiringTokensQuery, cutoff)
+	if err != nil {
+		slog.Error("could not list expiring spotify tokens", "error", err)
+		return
+	}
+
+	var active map[int64]bool
+	if m.ActiveUsers != nil {
+		active = make(map[int64]bool)
+		for _, userId := range m.ActiveUsers() {
+			active[userId] = true
+		}
+	}
+
+	var userIds []int64
+	for rows.Next() {
+		var userId int64
+		if err := rows.Scan(&userId); err != nil {
+			slog.Error("could not read expiring spotify token", "error", err)
+			continue
+		}
+		if active == nil || active[userId] {
+			userIds = append(userIds, userId)
+		}
+	}
+	rows.Close()
+
+	for _, userId := range userIds {
+		if _, err := m.Refresh(ctx, userId); err != nil {
+			slog.Warn("background spotify token refresh failed", "user_id", userId, "error", err)
+		}
+	}
+}
+
+// Refresh refreshes the user's token, joining a refresh that is already
+// running for the same user instead of starting another.
+func (m *TokenManager) Refresh(ctx context.Context, userId int64) (*SpotifyTokenObject, error) {
+	m.Lock()
+	if call, ok := m.inflight[userId]; ok {
+		m.Unlock()
+		select {
+		case <-call.done:
+			return call.token, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	call := &refreshCall{done: make(chan struct{})}
+	m.inflight[userId] = call
+	m.Unlock()
+
+	// The refresh outlives a cancelled caller so that waiters still get a
+	// result and Spotify's rotated refresh token is not lost.
+	call.token, call.err = m.refresh(context.WithoutCancel(ctx), userId)
+
+	m.Lock()
+	delete(m.inflight, userId)
+	m.Unlock()
+	close(call.done)
+
+	return call.token, call.err
+}
+
+func (m *TokenManager) refresh(ctx context.Context, userId int64) (*SpotifyTokenObject, error) {
+	token, err := GetTokenFromDB(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err = RefreshToken(ctx, token.RefreshToken, userId)
+
+	var tokenError *TokenRequestError
+	if errors.As(err, &tokenError) && tokenError.Revoked() {
+		m.revoke(userId)
+	}
+	return token, err
+}
+
+func (m *TokenManager) revoke(userId int64) {
+	slog.Warn("spotify grant revoked", "user_id", userId)
+
+	// The Spotify account is still the user's, so it stays linked and
+	// signing in with Spotify grants access again.
+	if err := m.forget(userId); err != nil {
+		slog.Error("could not forget revoked spotify grant", "user_id", userId, "error", err)
+	}
+}
+
+// Disconnect unlinks the user's Spotify account at their request, so it can
+// no longer be used to sign in to theirs.
+func (m *TokenManager) Disconnect(userId int64) error {
+	if _, err := storage.DB.Exec(storage.UnlinkSpotifyQuery, userId); err != nil {
+		return err
+	}
+	return m.forget(userId)
+}
+
+// forget drops the user's Spotify grant and tells their rooms.
+func (m *TokenManager) forget(userId int64) error {
+	if _, err := storage.DB.Exec(storage.DeleteTokenQuery, userId); err != nil {
+		return err
+	}
+	if _, err := storage.DB.Exec(storage.ActivateSpotifyQuery, false, userId); err != nil {
+		return err
+	}
+
+	if m.OnRevoked != nil {
+		m.OnRevoked(userId)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"houseparty.com/storage"
+)
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	storage.OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	t.Cleanup(func() { storage.DB.Close() })
+}
+
+// fakeAccounts stands in for the Spotify accounts service. Each token
+// request waits for release to be closed, then gets a new access token.
+func fakeAccounts(t *testing.T, release <-chan struct{}) *atomic.Int32 {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","expires_in":3600,"scope":"streaming"}`, n)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("SPOTIFY_ACCOUNTS_URL", server.URL)
+	t.Setenv("SPOTIFY_CLIENT_ID", "client")
+	t.Setenv("SPOTIFY_CLIENT_SECRET", "secret")
+	return &requests
+}
+
+func saveTestToken(t *testing.T, userId int64) {
+	t.Helper()
+	token := SpotifyTokenObject{AccessToken: "old", TokenType: "Bearer", ExpiresIn: 3600, RefreshToken: "refresh", TimeIssued: 0, UserID: userId}
+	if err := token.SaveToken(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshSharesOneRequestBetweenCallers(t *testing.T) {
+	openTestDB(t)
+	release := make(chan struct{})
+	requests := fakeAccounts(t, release)
+	saveTestToken(t, 1)
+
+	manager := NewTokenManager()
+	const callers = 20
+	var wg sync.WaitGroup
+	tokens := make([]*SpotifyTokenObject, callers)
+	errs := make([]error, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], errs[i] = manager.Refresh(context.Background(), 1)
+		}()
+	}
+
+	// Give every caller time to join the refresh before it finishes.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d token requests, want 1", n)
+	}
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("caller %d: %v", i, errs[i])
+		}
+		if tokens[i].AccessToken != "access-1" {
+			t.Errorf("caller %d got %q, want access-1", i, tokens[i].AccessToken)
+		}
+	}
+
+	stored, err := GetTokenFromDB(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.AccessToken != "access-1" || stored.RefreshToken != "refresh" {
+		t.Errorf("stored token = %q/%q, want access-1/refresh", stored.AccessToken, stored.RefreshToken)
+	}
+}
+
+func TestRefreshWaiterCanGiveUpWithoutCancellingRefresh(t *testing.T) {
+	openTestDB(t)
+	release := make(chan struct{})
+	requests := fakeAccounts(t, release)
+	saveTestToken(t, 1)
+
+	manager := NewTokenManager()
+	first := make(chan error, 1)
+	go func() {
+		_, err := manager.Refresh(context.Background(), 1)
+		first <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := manager.Refresh(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waiter error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatalf("refresh failed after a waiter gave up: %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d token requests, want 1", n)
+	}
+}
+
+func TestRefreshStartsAgainAfterPreviousFinished(t *testing.T) {
+	openTestDB(t)
+	release := make(chan struct{})
+	close(release)
+	requests := fakeAccounts(t, release)
+	saveTestToken(t, 1)
+
+	manager := NewTokenManager()
+	for i := range 2 {
+		if _, err := manager.Refresh(context.Background(), 1); err != nil {
+			t.Fatalf("refresh %d: %v", i+1, err)
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d token requests, want 2", n)
+	}
+}
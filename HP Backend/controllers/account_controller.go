@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8,max=72,password"`
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+func GetProfile(context *gin.Context) {
+	var user models.User
+	if err := user.GetUserById(context.GetInt64("userId")); err != nil {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Could not get user", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "fetched user", "user": user.ToUserResponse()})
+}
+
+func UpdateProfile(context *gin.Context) {
+	var update services.ProfileUpdate
+	if !bindJSON(context, &update) {
+		return
+	}
+
+	user, err := services.UpdateProfile(context.Request.Context(), context.GetInt64("userId"), update)
+	switch {
+	case errors.Is(err, services.ErrInvalidProfile):
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not update user", "error": err.Error()})
+		return
+	case errors.Is(err, services.ErrUsernameInUse), errors.Is(err, services.ErrEmailInUse):
+		context.JSON(http.StatusConflict, gin.H{"message": "Could not update user", "error": err.Error()})
+		return
+	case err != nil:
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update user", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "User updated", "user": user.ToUserResponse()})
+}
+
+func ChangePassword(context *gin.Context) {
+	var request changePasswordRequest
+	if !bindJSON(context, &request) {
+		return
+	}
+
+	err := services.ChangePassword(context.GetInt64("userId"), context.GetString("sessionId"), request.CurrentPassword, request.NewPassword)
+	if errors.Is(err, services.ErrWrongPassword) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Could not change password", "error": err.Error()})
+		return
+	} else if errors.Is(err, services.ErrNoPassword) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Could not change password", "error": err.Error()})
+		return
+	} else if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not change password", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Password changed, other sessions have been logged out"})
+}
+
+func DisconnectSpotify(context *gin.Context) {
+	err := services.DisconnectSpotify(context.GetInt64("userId"))
+	if errors.Is(err, services.ErrNoPassword) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Could not disconnect spotify", "error": err.Error()})
+		return
+	} else if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not disconnect spotify", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "spotify disconnected", "connected": false})
+}
+
+func DeleteAccount(context *gin.Context) {
+	var request deleteAccountRequest
+	if !bindJSON(context, &request) {
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	roomIds, err := services.DeleteAccount(context.Request.Context(), userId, request.Password)
+	if errors.Is(err, services.ErrWrongPassword) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Could not delete account", "error": err.Error()})
+		return
+	} else if errors.Is(err, services.ErrNoPassword) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Could not delete account", "error": err.Error()})
+		return
+	} else if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete account", "error": err.Error()})
+		return
+	}
+	manager.RemoveUser(userId, roomIds)
+
+	context.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"houseparty.com/logging"
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+func UnlockUser(context *gin.Context) {
+	userId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse user id", "error": err.Error()})
+		return
+	}
+
+	err = services.UnlockAccount(userId)
+	if errors.Is(err, models.ErrUserNotFound) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Could not unlock user", "error": err.Error()})
+		return
+	} else if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not unlock user", "error": err.Error()})
+		return
+	}
+
+	logging.FromContext(context.Request.Context()).Info("account unlocked", "user_id", userId, "admin_id", context.GetInt64("userId"))
+	context.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"houseparty.com/config"
+	"houseparty.com/logging"
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func RefreshToken(context *gin.Context) {
+	var request refreshTokenRequest
+	if !bindJSON(context, &request) {
+		return
+	}
+
+	tokens, err := services.RefreshSession(request.RefreshToken)
+	switch {
+	case errors.Is(err, services.ErrRefreshTokenReused):
+		logging.FromContext(context.Request.Context()).Warn("refresh token reused, session revoked")
+		fallthrough
+	case errors.Is(err, services.ErrInvalidRefreshToken), errors.Is(err, services.ErrSessionRevoked):
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Could not refresh token", "error": err.Error()})
+		return
+	case err != nil:
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not refresh token", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message":       "Token refreshed",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
+	})
+}
+
+func Logout(context *gin.Context) {
+	sessionId := context.GetString("sessionId")
+
+	if err := services.Logout(sessionId); err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not log out", "error": err.Error()})
+		return
+	}
+	manager.CloseSession(sessionId)
+
+	context.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,max=254,email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=72,password"`
+}
+
+func VerifyEmail(context *gin.Context) {
+	var request verifyEmailRequest
+	if !bindJSON(context, &request) {
+		return
+	}
+
+	user, err := services.VerifyEmail(request.Token)
+	if errors.Is(err, models.ErrInvalidUserToken) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not verify email", "error": err.Error()})
+		return
+	} else if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not verify email", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Email verified", "user": user.ToUserResponse()})
+}
+
+func ResendVerificationEmail(context *gin.Context) {
+	var user models.User
+	if err := user.GetUserById(context.GetInt64("userId")); err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not get user", "error": err.Error()})
+		return
+	}
+
+	err := services.SendVerificationEmail(context.Request.Context(), &user)
+	if errors.Is(err, services.ErrEmailAlreadyVerified) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Could not send verification email", "error": err.Error()})
+		return
+	} else if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not send verification email", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+func ForgotPassword(context *gin.Context) {
+	var request forgotPasswordRequest
+	if !bindJSON(context, &request) {
+		return
+	}
+
+	services.RequestPasswordReset(context.Request.Context(), request.Email)
+	context.JSON(http.StatusAccepted, gin.H{"message": "If that email belongs to an account, a reset link is on its way"})
+}
+
+func ResetPassword(context *gin.Context) {
+	var request resetPasswordRequest
+	if !bindJSON(context, &request) {
+		return
+	}
+
+	err := services.ResetPassword(request.Token, request.Password)
+	if errors.Is(err, models.ErrInvalidUserToken) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not reset password", "error": err.Error()})
+		return
+	} else if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not reset password", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
+
+type spotifyLoginRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+func SpotifyLoginURL(context *gin.Context) {
+	authUrl, err := config.GenerateSpotifyAuthRequest(config.LoginStateUserID)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not create spotify login", "error": err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Url Generated", "auth_url": authUrl})
+}
+
+func SpotifyLogin(context *gin.Context) {
+	var request spotifyLoginRequest
+	if !bindJSON(context, &request) {
+		return
+	}
+
+	login, err := services.LoginWithSpotify(context.Request.Context(), request.Code, request.State)
+	var tokenError *config.TokenRequestError
+	switch {
+	case errors.Is(err, config.ErrInvalidOAuthState), errors.As(err, &tokenError):
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not log in with spotify", "error": err.Error()})
+		return
+	case errors.Is(err, services.ErrSpotifyNotLinked):
+		context.JSON(http.StatusConflict, gin.H{"message": "Could not log in with spotify", "error": err.Error()})
+		return
+	case errors.Is(err, services.ErrSpotifyNoEmail):
+		context.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Could not log in with spotify", "error": err.Error()})
+		return
+	case err != nil:
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not log in with spotify", "error": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if login.Created {
+		status = http.StatusCreated
+	}
+	context.JSON(status, gin.H{
+		"message":       "User has been logged in",
+		"created":       login.Created,
+		"user":          login.User.ToUserResponse(),
+		"token":         login.Tokens.AccessToken,
+		"refresh_token": login.Tokens.RefreshToken,
+		"expires_at":    login.Tokens.ExpiresAt,
+	})
+}
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"houseparty.com/validation"
+)
+
+// bindJSON decodes the request body into obj and checks its binding rules.
+// Malformed JSON is a 400, while JSON that breaks a rule is a 422 listing
+// each failing field. It reports whether the handler can carry on.
+func bindJSON(context *gin.Context, obj any) bool {
+	err := context.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	if fields, ok := validation.FromError(err); ok {
+		respondInvalid(context, "Invalid input", fields)
+		return false
+	}
+	context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse data", "error": err.Error()})
+	return false
+}
+
+func respondInvalid(context *gin.Context, message string, fields validation.Errors) {
+	context.JSON(http.StatusUnprocessableEntity, gin.H{"message": message, "error": fields.Error(), "fields": fields})
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"houseparty.com/services"
+)
+
+func GetCacheStats(context *gin.Context) {
+	stats, err := services.GetCacheStats()
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not read cache stats", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "fetched cache stats", "stats": stats})
+}
+
+func ClearSearchCache(context *gin.Context) {
+	services.InvalidateSearchCache()
+	context.JSON(http.StatusOK, gin.H{"message": "search cache cleared"})
+}
+
+func InvalidateTrackCache(context *gin.Context) {
+	err := services.InvalidateTrackCache(context.Param("provider"), context.Param("id"))
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not invalidate track cache", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "track cache invalidated"})
+}
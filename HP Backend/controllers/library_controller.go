@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"houseparty.com/config"
+	"houseparty.com/library"
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+func UploadLocalTrack(context *gin.Context) {
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, config.GetLibraryMaxUpload())
+
+	fileHeader, err := context.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			context.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "File is too large", "error": err.Error()})
+			return
+		}
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not read upload", "error": err.Error()})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not read upload", "error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	track, err := services.UploadLocalTrack(context.GetInt64("userId"), fileHeader.Filename, file)
+	switch {
+	case errors.Is(err, library.ErrUnsupportedFormat):
+		context.JSON(http.StatusUnsupportedMediaType, gin.H{"message": "Could not add track", "error": err.Error()})
+		return
+	case errors.Is(err, models.ErrLibraryQuotaExceeded):
+		context.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "Could not add track", "error": err.Error()})
+		return
+	case errors.Is(err, services.ErrUnreadableAudio):
+		context.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Could not add track", "error": err.Error()})
+		return
+	case err != nil:
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not add track", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "track added", "song": track.ToSong()})
+}
+
+func ListLocalTracks(context *gin.Context) {
+	offset, _ := strconv.Atoi(context.Query("offset"))
+	limit, _ := strconv.Atoi(context.Query("limit"))
+
+	songs, total, err := services.ListLocalTracks(context.GetInt64("userId"), context.Query("search"), offset, limit)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not list tracks", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "fetched tracks", "songs": songs, "total": total})
+}
+
+func StreamLocalTrack(context *gin.Context) {
+	track, file, err := services.OpenLocalTrack(context.Param("id"))
+	if err != nil {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Track not found", "error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	context.Header("Content-Type", library.ContentType(track.Format))
+	context.Header("X-Content-Type-Options", "nosniff")
+	context.Header("Cache-Control", "private, max-age=86400")
+	http.ServeContent(context.Writer, context.Request, track.ID+"."+track.Format, track.UploadedAt, file)
+}
+
+func LocalTrackCover(context *gin.Context) {
+	track, file, err := services.OpenLocalCover(context.Param("id"))
+	if err != nil {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Cover not found", "error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	context.Header("Content-Type", track.CoverMIME)
+	context.Header("X-Content-Type-Options", "nosniff")
+	context.Header("Cache-Control", "private, max-age=86400")
+	http.ServeContent(context.Writer, context.Request, "", track.UploadedAt, file)
+}
+
+func DeleteLocalTrack(context *gin.Context) {
+	err := services.DeleteLocalTrack(context.Param("id"), context.GetInt64("userId"))
+	switch {
+	case errors.Is(err, services.ErrTrackNotOwned):
+		context.JSON(http.StatusForbidden, gin.H{"message": "Could not delete track", "error": err.Error()})
+		return
+	case errors.Is(err, models.ErrTrackNotFound):
+		context.JSON(http.StatusNotFound, gin.H{"message": "Could not delete track", "error": err.Error()})
+		return
+	case err != nil:
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete track", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "track deleted"})
+}
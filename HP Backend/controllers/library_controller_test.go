@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"houseparty.com/models"
+	"houseparty.com/services"
+	"houseparty.com/storage"
+)
+
+var pngCover = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+
+// mp3WithCover builds a short constant bitrate MP3 whose ID3v2.3 tag embeds
+// picture, declared as mime.
+func mp3WithCover(mime string, picture []byte) []byte {
+	var apic bytes.Buffer
+	apic.WriteByte(0) // ISO-8859-1
+	apic.WriteString(mime + "\x00")
+	apic.WriteByte(3) // front cover
+	apic.WriteString("\x00")
+	apic.Write(picture)
+
+	var frames bytes.Buffer
+	frames.WriteString("TIT2")
+	binary.Write(&frames, binary.BigEndian, uint32(len("Song")+1))
+	frames.Write([]byte{0, 0, 0})
+	frames.WriteString("Song")
+	frames.WriteString("APIC")
+	binary.Write(&frames, binary.BigEndian, uint32(apic.Len()))
+	frames.Write([]byte{0, 0})
+	frames.Write(apic.Bytes())
+
+	var file bytes.Buffer
+	size := frames.Len()
+	file.WriteString("ID3")
+	file.Write([]byte{3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)})
+	file.Write(frames.Bytes())
+
+	// MPEG-1 layer III, 128kbps, 44.1kHz: 417 byte frames.
+	for range 20 {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+		file.Write(frame)
+	}
+	return file.Bytes()
+}
+
+func setupLibrary(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("LOCAL_LIBRARY_DIR", t.TempDir())
+	storage.OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	t.Cleanup(func() { storage.DB.Close() })
+	services.InitProviders(false)
+
+	router := gin.New()
+	router.POST("/library/upload", func(context *gin.Context) {
+		context.Set("userId", int64(1))
+	}, UploadLocalTrack)
+	router.GET("/library/tracks/:id/cover", LocalTrackCover)
+	return router
+}
+
+func upload(t *testing.T, router *gin.Engine, file []byte) (int, models.Song) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "song.mp3")
+	part.Write(file)
+	form.Close()
+
+	request := httptest.NewRequest(http.MethodPost, "/library/upload", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	var response struct {
+		Song models.Song `json:"song"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder.Code, response.Song
+}
+
+func getCover(router *gin.Engine, id string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/library/tracks/"+id+"/cover", nil))
+	return recorder
+}
+
+func TestUploadServesSniffedCoverType(t *testing.T) {
+	router := setupLibrary(t)
+
+	// The tag claims HTML, but the picture is a PNG.
+	status, song := upload(t, router, mp3WithCover("text/html", pngCover))
+	if status != http.StatusCreated {
+		t.Fatalf("upload status = %d, want %d", status, http.StatusCreated)
+	}
+	if song.Image.URL == "" {
+		t.Fatal("uploaded song has no cover")
+	}
+
+	recorder := getCover(router, song.Id)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("cover status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", got)
+	}
+	if got := recorder.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+}
+
+func TestUploadDropsCoverThatIsNotAnImage(t *testing.T) {
+	router := setupLibrary(t)
+
+	for _, picture := range []string{
+		"<html><script>alert(document.cookie)</script></html>",
+		`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`,
+	} {
+		status, song := upload(t, router, mp3WithCover("image/png", []byte(picture)))
+		if status != http.StatusCreated {
+			t.Fatalf("upload status = %d, want %d", status, http.StatusCreated)
+		}
+		if song.Image.URL != "" {
+			t.Errorf("song has cover %q for %q", song.Image.URL, picture)
+		}
+		if recorder := getCover(router, song.Id); recorder.Code != http.StatusNotFound {
+			t.Errorf("cover status = %d, want %d", recorder.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestCoverStoredWithUnsafeTypeIsNotServed(t *testing.T) {
+	router := setupLibrary(t)
+
+	track := models.LocalTrack{ID: "legacy", OwnerID: 1, Format: "mp3", Artists: []string{}, CoverMIME: "text/html", UploadedAt: time.Now()}
+	if err := track.Save(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(os.Getenv("LOCAL_LIBRARY_DIR"), "legacy.cover"), []byte("<script>alert(1)</script>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := getCover(router, "legacy")
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("cover status = %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+	if strings.Contains(recorder.Body.String(), "<script>") {
+		t.Error("unsafe cover was served")
+	}
+}
+
+func TestUploadOverQuotaIsRejected(t *testing.T) {
+	router := setupLibrary(t)
+	t.Setenv("LOCAL_LIBRARY_QUOTA_MB", "1")
+
+	existing := models.LocalTrack{ID: "big", OwnerID: 1, Format: "mp3", Artists: []string{}, Size: 1<<20 - 100, UploadedAt: time.Now()}
+	if err := existing.Save(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+
+	status, _ := upload(t, router, mp3WithCover("image/png", pngCover))
+	if status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("upload status = %d, want %d", status, http.StatusRequestEntityTooLarge)
+	}
+
+	entries, _ := os.ReadDir(os.Getenv("LOCAL_LIBRARY_DIR"))
+	if len(entries) != 0 {
+		t.Errorf("rejected upload left %d files behind", len(entries))
+	}
+}
@@ -1,13 +1,17 @@
 package controllers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"houseparty.com/config"
+	"houseparty.com/logging"
 	"houseparty.com/models"
 	"houseparty.com/services"
+	"houseparty.com/validation"
 	"houseparty.com/websockets"
 )
 
@@ -20,14 +24,15 @@ func InitManager(m *websockets.Manager) {
 func CreateNewRoom(context *gin.Context) {
 	var room models.Room
 
-	err := context.ShouldBindJSON(&room)
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not parse data", "error": err.Error()})
+	if !bindJSON(context, &room) {
 		return
 	}
 
-	err = services.CreateRoom(&room, context.GetInt64("userId"))
-	if err != nil {
+	err := services.CreateRoom(&room, context.GetInt64("userId"))
+	if fields, ok := validation.FromError(err); ok {
+		respondInvalid(context, "Could not create room", fields)
+		return
+	} else if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create room", "error": err.Error()})
 		return
 	}
@@ -39,7 +44,7 @@ func RetieveRooms(context *gin.Context) {
 
 	publicRooms, userRoom, err := services.GetRooms(context.GetInt64("userId"))
 	if err != nil {
-		log.Println(err.Error())
+		logging.FromContext(context.Request.Context()).Error("could not retrieve rooms", "error", err)
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not retrive rooms", "error": err.Error()})
 		return
 	}
@@ -56,9 +61,7 @@ func DeleteRoom(context *gin.Context) {
 		return
 	}
 
-	manager.Lock()
-	defer manager.Unlock()
-	delete(manager.Rooms, roomId)
+	manager.DeleteRoom(roomId)
 
 	room, err := services.DeleteRoomByID(roomId)
 	if err != nil {
@@ -72,3 +75,97 @@ func DeleteRoom(context *gin.Context) {
 func JoinRoom(context *gin.Context) {
 	manager.ServeWs()(context)
 }
+
+func IssueRoomTicket(context *gin.Context) {
+	ticket, roomTicket, err := services.IssueRoomTicket(context.Param("id"), context.GetInt64("userId"), context.GetString("sessionId"))
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Could not issue ticket", "error": "room not found"})
+		return
+	} else if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not issue ticket", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "ticket issued", "ticket": ticket, "expires_at": roomTicket.ExpiresAt})
+}
+
+type importSongsRequest struct {
+	Link string `json:"link" binding:"required,max=500"`
+}
+
+func ImportSongs(context *gin.Context) {
+	var request importSongsRequest
+	if !bindJSON(context, &request) {
+		return
+	}
+
+	var user models.User
+	err := user.GetUserById(context.GetInt64("userId"))
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not load user", "error": err.Error()})
+		return
+	}
+
+	result, err := manager.ImportSongs(context.Request.Context(), context.Param("id"), user.Id, user.Username, request.Link)
+	if err != nil && result == nil {
+		context.JSON(importErrorStatus(err), gin.H{"message": "Could not import songs", "error": err.Error()})
+		return
+	}
+	if err != nil {
+		logging.FromContext(context.Request.Context()).Warn("song import stopped early", "imported", result.Imported, "error", err)
+		context.JSON(http.StatusOK, gin.H{"message": "songs partially imported", "result": result, "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "songs imported", "result": result})
+}
+
+func importErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, websockets.ErrRoomNotActive):
+		return http.StatusNotFound
+	case errors.Is(err, websockets.ErrNotRoomHost):
+		return http.StatusForbidden
+	case errors.Is(err, websockets.ErrImportInProgress):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUnsupportedLink):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrProviderUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func ExportRoomHistory(context *gin.Context) {
+	var options services.ExportOptions
+	if context.Request.ContentLength > 0 && !bindJSON(context, &options) {
+		return
+	}
+
+	playlist, err := services.ExportRoomHistory(context.Request.Context(), context.Param("id"), context.GetInt64("userId"), options)
+	if err != nil {
+		logging.FromContext(context.Request.Context()).Warn("could not export room history", "room_id", context.Param("id"), "error", err)
+		context.JSON(exportErrorStatus(err), gin.H{"message": "Could not export room history", "error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "playlist created", "playlist": playlist})
+}
+
+func exportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrRoomAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrNothingToExport), errors.Is(err, services.ErrExportUnsupported):
+		return http.StatusBadRequest
+	case errors.Is(err, config.ErrTokenNotFound), errors.Is(err, services.ErrMissingScope):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrProviderUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,8 +1,10 @@
 package controllers
 
 import (
-	"log"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
@@ -14,42 +16,70 @@ import (
 func SignUp(context *gin.Context) {
 	var user models.User
 
-	err := context.ShouldBindJSON(&user)
-	if err != nil {
-		context.JSON(http.StatusInternalServerError,  gin.H{"message": "Could not bind user", "error": err.Error()})
+	if !bindJSON(context, &user) {
 		return
 	}
 
-	token, err := services.CreateNewUser(&user)
-	if err != nil {
+	tokens, err := services.CreateNewUser(context.Request.Context(), &user)
+	if errors.Is(err, services.ErrUsernameInUse) || errors.Is(err, services.ErrEmailInUse) {
+		context.JSON(http.StatusConflict,  gin.H{"message": "Could not create new user", "error": err.Error()})
+		return
+	} else if err != nil {
 		context.JSON(http.StatusInternalServerError,  gin.H{"message": "Could not create new user", "error": err.Error()})
 		return
 	}
 
-	context.JSON(http.StatusCreated, gin.H{"message": "User created", "user": user.ToUserResponse(), "token": token})
+	context.JSON(http.StatusCreated, gin.H{
+		"message":       "User created",
+		"user":          user.ToUserResponse(),
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
+	})
 }
 
-func Login(context *gin.Context){
-	var user models.User
+// loginRequest takes either an email or a username. Sign up rules are not
+// applied so that older accounts can still log in.
+type loginRequest struct {
+	Username string `json:"username" binding:"required_without=Email,max=254"`
+	Email    string `json:"email" binding:"required_without=Username,max=254"`
+	Password string `json:"password" binding:"required,max=72"`
+}
 
-	err := context.ShouldBindJSON(&user)
-	if err != nil {
-		context.JSON(http.StatusInternalServerError,  gin.H{"message": "Could not bind user", "error": err.Error()})
+func Login(context *gin.Context){
+	var request loginRequest
+	if !bindJSON(context, &request) {
 		return
 	}
 
-	token, err := services.ValidateCredentials(&user)
-	if err != nil {
+	user := models.User{Username: request.Username, Email: request.Email, Password: request.Password}
+
+	tokens, err := services.ValidateCredentials(context.Request.Context(), &user, context.ClientIP())
+	var throttled *services.LoginThrottledError
+	if errors.As(err, &throttled) {
+		context.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		context.JSON(http.StatusTooManyRequests,  gin.H{"message": "Could not validate user", "error": err.Error()})
+		return
+	} else if errors.Is(err, services.ErrInvalidCredentials) {
 		context.JSON(http.StatusUnauthorized,  gin.H{"message": "Could not validate user", "error": err.Error()})
 		return
+	} else if err != nil {
+		context.JSON(http.StatusInternalServerError,  gin.H{"message": "Could not validate user", "error": err.Error()})
+		return
 	}
 
 
-	context.JSON(http.StatusOK, gin.H{"message": "User has been logged in", "user": user.ToUserResponse(), "token": token})
+	context.JSON(http.StatusOK, gin.H{
+		"message":       "User has been logged in",
+		"user":          user.ToUserResponse(),
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
+	})
 }
 
 func SpotifyAuthToken(context *gin.Context){
-	authUrl, err := config.GenerateSpotifyAuthRequest()
+	authUrl, err := config.GenerateSpotifyAuthRequest(context.GetInt64("userId"))
 	if err != nil {
 		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -70,34 +100,43 @@ func SpotifyTokenCallBack(context *gin.Context){
 		context.JSON(http.StatusBadRequest, gin.H{"error": "Code query parameter is missing"})
 		return
 	}
-	token, err := config.SetSpotifyToken(code, user.Id)
+
+	codeVerifier, err := config.ConsumeOAuthState(context.Query("state"), user.Id)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could get and save token", "error": err.Error()})
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not verify spotify authorization", "error": err.Error()})
 		return
 	}
 
-	err = user.ActivateSpotify()
-	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not update user", "error": err.Error()})
+	token, err := services.ConnectSpotify(context.Request.Context(), &user, code, codeVerifier)
+	if errors.Is(err, services.ErrSpotifyAccountLinked) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Could not connect spotify", "error": err.Error()})
+		return
+	} else if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could get and save token", "error": err.Error()})
 		return
 	}
 
 	context.JSON(http.StatusOK, gin.H{"message": "Token has been stored in DB", "token": token})
 }
 
-func TestGetToken(context *gin.Context){
-	token, err := config.GetSpotifyTokenObject(1)
-	log.Println(token)
+func SpotifyConnectionStatus(context *gin.Context){
+	var user models.User
+	err := user.GetUserById(context.GetInt64("userId"))
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not get user", "error": err.Error()})
 		return
 	}
 
-	token, err = config.RefreshToken(token.RefreshToken, 1)
-	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	token, err := config.GetTokenFromDB(user.Id)
+	if err != nil || !user.SpotifyConnected {
+		context.JSON(http.StatusOK, gin.H{"message": "spotify not connected", "connected": false})
 		return
 	}
 
-	context.JSON(http.StatusOK, gin.H{"message": "spotify connected", "token": token})
+	context.JSON(http.StatusOK, gin.H{
+		"message":    "spotify connected",
+		"connected":  true,
+		"scope":      token.Scope,
+		"expires_at": token.TimeIssued + token.ExpiresIn,
+	})
 }
\ No newline at end of file
@@ -0,0 +1,105 @@
+package httpclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCircuitOpen   = errors.New("circuit breaker is open")
+	errNotRewindable = errors.New("request body cannot be replayed for retry")
+)
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// Breaker opens after Threshold consecutive failures and rejects calls until
+// Cooldown has passed, then lets a single trial call through.
+type Breaker struct {
+	sync.Mutex
+	Threshold int
+	Cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+}
+
+func (b *Breaker) Allow() error {
+	b.Lock()
+	defer b.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = stateHalfOpen
+		return nil
+	case stateHalfOpen:
+		return ErrCircuitOpen
+	default:
+		return nil
+	}
+}
+
+func (b *Breaker) Success() {
+	b.Lock()
+	defer b.Unlock()
+	b.state = stateClosed
+	b.failures = 0
+}
+
+func (b *Breaker) Failure() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.Threshold {
+		b.state = stateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Neutral ends a half-open trial whose outcome says nothing about the
+// upstream's health, such as a 404, without changing the failure count.
+func (b *Breaker) Neutral() {
+	b.Lock()
+	defer b.Unlock()
+	if b.state == stateHalfOpen {
+		b.state = stateClosed
+	}
+}
+
+// BreakerSet lazily creates one breaker per key.
+type BreakerSet[K comparable] struct {
+	sync.Mutex
+	Threshold int
+	Cooldown  time.Duration
+	breakers  map[K]*Breaker
+}
+
+func NewBreakerSet[K comparable](threshold int, cooldown time.Duration) *BreakerSet[K] {
+	return &BreakerSet[K]{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		breakers:  make(map[K]*Breaker),
+	}
+}
+
+func (s *BreakerSet[K]) Get(key K) *Breaker {
+	s.Lock()
+	defer s.Unlock()
+
+	breaker, ok := s.breakers[key]
+	if !ok {
+		breaker = &Breaker{Threshold: s.Threshold, Cooldown: s.Cooldown}
+		s.breakers[key] = breaker
+	}
+	return breaker
+}
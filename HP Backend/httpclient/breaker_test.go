@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	breaker := &Breaker{Threshold: 3, Cooldown: time.Hour}
+
+	for range 2 {
+		breaker.Failure()
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow below the threshold = %v, want nil", err)
+	}
+
+	breaker.Failure()
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow after %d failures = %v, want %v", breaker.Threshold, err, ErrCircuitOpen)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	breaker := &Breaker{Threshold: 2, Cooldown: time.Hour}
+
+	breaker.Failure()
+	breaker.Success()
+	breaker.Failure()
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow = %v, want nil since failures were not consecutive", err)
+	}
+}
+
+func TestBreakerHalfOpenTrial(t *testing.T) {
+	tests := []struct {
+		name      string
+		outcome   func(*Breaker)
+		wantAllow bool
+	}{
+		{name: "success closes", outcome: (*Breaker).Success, wantAllow: true},
+		{name: "neutral closes", outcome: (*Breaker).Neutral, wantAllow: true},
+		{name: "failure reopens", outcome: (*Breaker).Failure, wantAllow: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			breaker := &Breaker{Threshold: 1, Cooldown: 10 * time.Millisecond}
+			breaker.Failure()
+			if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("Allow while open = %v, want %v", err, ErrCircuitOpen)
+			}
+
+			time.Sleep(breaker.Cooldown)
+			if err := breaker.Allow(); err != nil {
+				t.Fatalf("trial Allow after cooldown = %v, want nil", err)
+			}
+			if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("second Allow during the trial = %v, want %v", err, ErrCircuitOpen)
+			}
+
+			test.outcome(breaker)
+			if err := breaker.Allow(); (err == nil) != test.wantAllow {
+				t.Errorf("Allow after the trial = %v, want allowed %v", err, test.wantAllow)
+			}
+		})
+	}
+}
+
+func TestBreakerSetKeepsOneBreakerPerKey(t *testing.T) {
+	set := NewBreakerSet[int64](1, time.Hour)
+
+	set.Get(1).Failure()
+	if set.Get(1) != set.Get(1) {
+		t.Fatal("Get returned different breakers for the same key")
+	}
+	if err := set.Get(1).Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("key 1 Allow = %v, want %v", err, ErrCircuitOpen)
+	}
+	if err := set.Get(2).Allow(); err != nil {
+		t.Errorf("key 2 Allow = %v, want nil", err)
+	}
+}
@@ -0,0 +1,42 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	requestTimeout        = 30 * time.Second
+	dialTimeout           = 5 * time.Second
+	tlsHandshakeTimeout   = 5 * time.Second
+	responseHeaderTimeout = 10 * time.Second
+)
+
+// Shared is the client used for every outgoing Spotify call. Each attempt is
+// traced and transient failures are retried; the overall timeout covers all
+// attempts.
+var Shared = New()
+
+func New() *http.Client {
+	base := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: NewRetryTransport(otelhttp.NewTransport(base)),
+	}
+}
@@ -0,0 +1,224 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func response(status int, header http.Header) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(""))}
+}
+
+// scripted answers each attempt with the next status in statuses, where 0
+// stands for a network error, and counts the attempts.
+func scripted(statuses ...int) (*RetryTransport, *int) {
+	attempts := 0
+	transport := &RetryTransport{
+		Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			status := statuses[min(attempts, len(statuses)-1)]
+			attempts++
+			if status == 0 {
+				return nil, errors.New("connection reset")
+			}
+			return response(status, nil), nil
+		}),
+		MaxRetries:    3,
+		BaseDelay:     time.Millisecond,
+		MaxDelay:      time.Millisecond,
+		MaxRetryAfter: time.Second,
+	}
+	return transport, &attempts
+}
+
+func TestRetryTransportRetriesIdempotentRequests(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		header       http.Header
+		statuses     []int
+		wantStatus   int
+		wantAttempts int
+	}{
+		{name: "GET succeeds after 503", method: http.MethodGet, statuses: []int{503, 503, 200}, wantStatus: 200, wantAttempts: 3},
+		{name: "GET after network error", method: http.MethodGet, statuses: []int{0, 200}, wantStatus: 200, wantAttempts: 2},
+		{name: "GET gives up after MaxRetries", method: http.MethodGet, statuses: []int{502}, wantStatus: 502, wantAttempts: 4},
+		{name: "GET does not retry 404", method: http.MethodGet, statuses: []int{404}, wantStatus: 404, wantAttempts: 1},
+		{name: "PUT is retried", method: http.MethodPut, statuses: []int{429, 204}, wantStatus: 204, wantAttempts: 2},
+		{name: "DELETE is retried", method: http.MethodDelete, statuses: []int{500, 200}, wantStatus: 200, wantAttempts: 2},
+		{name: "POST is not retried", method: http.MethodPost, statuses: []int{503, 201}, wantStatus: 503, wantAttempts: 1},
+		{name: "POST with an idempotency key is retried", method: http.MethodPost, header: http.Header{"Idempotency-Key": {"abc"}}, statuses: []int{503, 201}, wantStatus: 201, wantAttempts: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport, attempts := scripted(test.statuses...)
+			req, _ := http.NewRequest(test.method, "https://api.example.com/v1/thing", strings.NewReader("{}"))
+			for key, values := range test.header {
+				req.Header[key] = values
+			}
+
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+			if *attempts != test.wantAttempts {
+				t.Errorf("attempts = %d, want %d", *attempts, test.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransportDoesNotRetryPostNetworkError(t *testing.T) {
+	transport, attempts := scripted(0, 200)
+	req, _ := http.NewRequest(http.MethodPost, "https://accounts.example.com/api/token", strings.NewReader("code=once"))
+
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatal("expected the network error")
+	}
+	if *attempts != 1 {
+		t.Errorf("attempts = %d, want 1", *attempts)
+	}
+}
+
+func TestRetryTransportReplaysBody(t *testing.T) {
+	var bodies []string
+	transport := &RetryTransport{
+		Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			bodies = append(bodies, string(body))
+			if len(bodies) == 1 {
+				return response(503, nil), nil
+			}
+			return response(200, nil), nil
+		}),
+		MaxRetries: 1,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, "https://api.example.com/v1/me/player/play", strings.NewReader(`{"uris":["a"]}`))
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || bodies[0] != bodies[1] {
+		t.Errorf("bodies = %q, want the same body twice", bodies)
+	}
+}
+
+func TestRetryTransportHonoursRetryAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryAfter   string
+		wantAttempts int
+	}{
+		// A backoff of an hour would time the test out, so finishing at all
+		// shows the header replaced it.
+		{name: "short Retry-After replaces the backoff", retryAfter: "0", wantAttempts: 2},
+		{name: "Retry-After beyond the limit is returned", retryAfter: "120", wantAttempts: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			transport := &RetryTransport{
+				Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					attempts++
+					if attempts == 1 {
+						return response(http.StatusTooManyRequests, http.Header{"Retry-After": {test.retryAfter}}), nil
+					}
+					return response(200, nil), nil
+				}),
+				MaxRetries:    3,
+				BaseDelay:     time.Hour,
+				MaxDelay:      time.Hour,
+				MaxRetryAfter: time.Minute,
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/v1/search", nil)
+			if _, err := transport.RoundTrip(req); err != nil {
+				t.Fatal(err)
+			}
+			if attempts != test.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, test.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransportStopsWhenContextIsCancelled(t *testing.T) {
+	transport, attempts := scripted(503)
+	transport.BaseDelay = time.Hour
+	transport.MaxDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.example.com/v1/search", nil)
+
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if *attempts != 1 {
+		t.Errorf("attempts = %d, want 1", *attempts)
+	}
+}
+
+func TestBackoffStaysWithinCeiling(t *testing.T) {
+	transport := &RetryTransport{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt, ceiling := range map[int]time.Duration{
+		0:  100 * time.Millisecond,
+		1:  200 * time.Millisecond,
+		3:  800 * time.Millisecond,
+		4:  time.Second,
+		70: time.Second, // the shift overflows
+	} {
+		for range 100 {
+			if delay := transport.backoff(attempt); delay < 0 || delay > ceiling {
+				t.Fatalf("backoff(%d) = %s, want within [0, %s]", attempt, delay, ceiling)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{value: "", wantOk: false},
+		{value: "3", want: 3 * time.Second, wantOk: true},
+		{value: "-1", wantOk: false},
+		{value: "soon", wantOk: false},
+		{value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), want: 0, wantOk: true},
+	}
+
+	for _, test := range tests {
+		got, ok := parseRetryAfter(test.value)
+		if got != test.want || ok != test.wantOk {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", test.value, got, ok, test.want, test.wantOk)
+		}
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got, ok := parseRetryAfter(future); !ok || got <= 55*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %s, %v, want about a minute", future, got, ok)
+	}
+}
@@ -0,0 +1,296 @@
+package library
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	FormatMP3  = "mp3"
+	FormatFLAC = "flac"
+	FormatOGG  = "ogg"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported audio format, expected MP3, FLAC or OGG")
+
+// ContentType returns the MIME type files of format are streamed with.
+func ContentType(format string) string {
+	switch format {
+	case FormatMP3:
+		return "audio/mpeg"
+	case FormatFLAC:
+		return "audio/flac"
+	case FormatOGG:
+		return "audio/ogg"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// probe identifies the container of r and reads the audio duration from it.
+// None of the supported formats store the duration in their tags, so it is
+// taken from the stream headers instead.
+func probe(r io.ReadSeeker, size int64) (string, time.Duration, error) {
+	start, err := skipID3v2(r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return "", 0, ErrUnsupportedFormat
+	}
+
+	switch {
+	case string(magic) == "fLaC":
+		duration, err := flacDuration(r)
+		return FormatFLAC, duration, err
+	case string(magic) == "OggS":
+		duration, err := oggDuration(r, size)
+		return FormatOGG, duration, err
+	}
+
+	duration, err := mp3Duration(r, start, size)
+	if err != nil {
+		return "", 0, err
+	}
+	return FormatMP3, duration, nil
+}
+
+// skipID3v2 positions r after a leading ID3v2 tag, if there is one, and
+// returns that offset.
+func skipID3v2(r io.ReadSeeker) (int64, error) {
+	header := make([]byte, 10)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:3]) != "ID3" {
+		_, err := r.Seek(0, io.SeekStart)
+		return 0, err
+	}
+
+	size := int64(header[6]&0x7f)<<21 | int64(header[7]&0x7f)<<14 | int64(header[8]&0x7f)<<7 | int64(header[9]&0x7f)
+	offset := 10 + size
+	if header[5]&0x10 != 0 {
+		offset += 10
+	}
+	return r.Seek(offset, io.SeekStart)
+}
+
+func flacDuration(r io.Reader) (time.Duration, error) {
+	// The first metadata block is always STREAMINFO: a 4 byte block header
+	// followed by 34 bytes, with the sample rate and total sample count
+	// packed into bytes 10 to 17.
+	block := make([]byte, 4+34)
+	if _, err := io.ReadFull(r, block); err != nil {
+		return 0, fmt.Errorf("reading FLAC stream info: %w", err)
+	}
+	if block[0]&0x7f != 0 {
+		return 0, errors.New("FLAC file does not start with stream info")
+	}
+
+	packed := binary.BigEndian.Uint64(block[4+10 : 4+18])
+	sampleRate := packed >> 44
+	samples := packed & (1<<36 - 1)
+	if sampleRate == 0 || samples == 0 {
+		return 0, errors.New("FLAC stream info has no length")
+	}
+	return samplesToDuration(samples, sampleRate), nil
+}
+
+const oggTailSize = 64 * 1024
+
+func oggDuration(r io.ReadSeeker, size int64) (time.Duration, error) {
+	// The first page holds the codec identification header.
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	head := make([]byte, 128)
+	n, _ := io.ReadFull(r, head)
+	head = head[:n]
+
+	var sampleRate, preSkip uint64
+	switch {
+	case bytes.Contains(head, []byte("\x01vorbis")):
+		i := bytes.Index(head, []byte("\x01vorbis"))
+		if len(head) < i+16 {
+			return 0, errors.New("truncated Vorbis header")
+		}
+		sampleRate = uint64(binary.LittleEndian.Uint32(head[i+12 : i+16]))
+	case bytes.Contains(head, []byte("OpusHead")):
+		i := bytes.Index(head, []byte("OpusHead"))
+		if len(head) < i+12 {
+			return 0, errors.New("truncated Opus header")
+		}
+		// Opus granule positions always count 48kHz samples.
+		sampleRate = 48000
+		preSkip = uint64(binary.LittleEndian.Uint16(head[i+10 : i+12]))
+	default:
+		return 0, ErrUnsupportedFormat
+	}
+
+	// The granule position of the last page is the total sample count.
+	tailStart := max(size-oggTailSize, 0)
+	if _, err := r.Seek(tailStart, io.SeekStart); err != nil {
+		return 0, err
+	}
+	tail, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	i := bytes.LastIndex(tail, []byte("OggS"))
+	if i < 0 || len(tail) < i+14 {
+		return 0, errors.New("OGG file has no final page")
+	}
+	granule := binary.LittleEndian.Uint64(tail[i+6 : i+14])
+	if sampleRate == 0 || granule <= preSkip {
+		return 0, errors.New("OGG stream has no length")
+	}
+	return samplesToDuration(granule-preSkip, sampleRate), nil
+}
+
+var (
+	mp3Bitrates = [2][16]int{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	}
+	mp3SampleRates = [3]int{44100, 48000, 32000}
+)
+
+type mp3Frame struct {
+	mpeg1      bool
+	mono       bool
+	sampleRate int
+	bitrate    int
+	length     int
+}
+
+const mp3SyncSearch = 64 * 1024
+
+// mp3Duration uses the frame count from a Xing/Info or VBRI header when the
+// encoder wrote one, and otherwise assumes a constant bitrate.
+func mp3Duration(r io.ReadSeeker, start, size int64) (time.Duration, error) {
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, mp3SyncSearch)
+	n, _ := io.ReadFull(r, buf)
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		frame, ok := parseMP3Header(buf[i : i+4])
+		if !ok {
+			continue
+		}
+		// A sync word can occur by chance, so insist that another frame
+		// follows where this one says it ends.
+		next := i + frame.length
+		if next+4 > len(buf) {
+			continue
+		}
+		if _, ok := parseMP3Header(buf[next : next+4]); !ok {
+			continue
+		}
+
+		samplesPerFrame := 1152
+		if !frame.mpeg1 {
+			samplesPerFrame = 576
+		}
+		if frames, ok := mp3FrameCount(buf[i:], frame); ok {
+			return samplesToDuration(uint64(frames)*uint64(samplesPerFrame), uint64(frame.sampleRate)), nil
+		}
+
+		audioBytes := size - start - int64(i) - id3v1Size(r, size)
+		if audioBytes <= 0 {
+			break
+		}
+		return time.Duration(audioBytes*8*1000/int64(frame.bitrate)) * time.Microsecond, nil
+	}
+	return 0, ErrUnsupportedFormat
+}
+
+func parseMP3Header(b []byte) (mp3Frame, bool) {
+	if b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return mp3Frame{}, false
+	}
+
+	version := (b[1] >> 3) & 0x03
+	layer := (b[1] >> 1) & 0x03
+	bitrateIndex := b[2] >> 4
+	sampleRateIndex := (b[2] >> 2) & 0x03
+	// Only MPEG layer III is MP3; version 1 is reserved.
+	if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return mp3Frame{}, false
+	}
+
+	frame := mp3Frame{mpeg1: version == 3, mono: b[3]>>6 == 3}
+	frame.sampleRate = mp3SampleRates[sampleRateIndex]
+	table := 0
+	switch version {
+	case 2:
+		frame.sampleRate /= 2
+		table = 1
+	case 0:
+		frame.sampleRate /= 4
+		table = 1
+	}
+	frame.bitrate = mp3Bitrates[table][bitrateIndex]
+
+	padding := int(b[2]>>1) & 0x01
+	if frame.mpeg1 {
+		frame.length = 144*frame.bitrate*1000/frame.sampleRate + padding
+	} else {
+		frame.length = 72*frame.bitrate*1000/frame.sampleRate + padding
+	}
+	return frame, true
+}
+
+func mp3FrameCount(frame []byte, header mp3Frame) (uint32, bool) {
+	sideInfo := 32
+	switch {
+	case header.mpeg1 && header.mono:
+		sideInfo = 17
+	case !header.mpeg1 && !header.mono:
+		sideInfo = 17
+	case !header.mpeg1 && header.mono:
+		sideInfo = 9
+	}
+
+	if xing := 4 + sideInfo; len(frame) >= xing+12 {
+		tag := string(frame[xing : xing+4])
+		flags := binary.BigEndian.Uint32(frame[xing+4 : xing+8])
+		if (tag == "Xing" || tag == "Info") && flags&0x01 != 0 {
+			frames := binary.BigEndian.Uint32(frame[xing+8 : xing+12])
+			return frames, frames > 0
+		}
+	}
+
+	if vbri := 4 + 32; len(frame) >= vbri+18 && string(frame[vbri:vbri+4]) == "VBRI" {
+		frames := binary.BigEndian.Uint32(frame[vbri+14 : vbri+18])
+		return frames, frames > 0
+	}
+	return 0, false
+}
+
+func id3v1Size(r io.ReadSeeker, size int64) int64 {
+	if size < 128 {
+		return 0
+	}
+	tag := make([]byte, 3)
+	if _, err := r.Seek(size-128, io.SeekStart); err != nil {
+		return 0
+	}
+	if _, err := io.ReadFull(r, tag); err != nil || string(tag) != "TAG" {
+		return 0
+	}
+	return 128
+}
+
+func samplesToDuration(samples, sampleRate uint64) time.Duration {
+	return time.Duration(samples * uint64(time.Second) / sampleRate)
+}
@@ -0,0 +1,98 @@
+package library
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+// coverTypes are the image formats covers are served as. Anything else, in
+// particular HTML or SVG, would run as a page on the API's origin.
+var coverTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+type Metadata struct {
+	Format    string
+	Title     string
+	Artists   []string
+	Album     string
+	Year      int
+	Duration  time.Duration
+	Cover     []byte
+	CoverMIME string
+}
+
+// Read extracts tags and the duration from an uploaded audio file. Files
+// without tags are accepted and only get a format and duration.
+func Read(r io.ReadSeeker, size int64) (*Metadata, error) {
+	format, duration, err := probe(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if duration <= 0 {
+		return nil, errors.New("could not determine the length of the file")
+	}
+
+	metadata := &Metadata{Format: format, Duration: duration}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	tags, err := tag.ReadFrom(r)
+	if errors.Is(err, tag.ErrNoTagsFound) {
+		return metadata, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.Title = strings.TrimSpace(tags.Title())
+	metadata.Album = strings.TrimSpace(tags.Album())
+	metadata.Year = tags.Year()
+	metadata.Artists = splitArtists(tags.Artist())
+	if len(metadata.Artists) == 0 {
+		metadata.Artists = splitArtists(tags.AlbumArtist())
+	}
+	if picture := tags.Picture(); picture != nil && len(picture.Data) > 0 {
+		if mime := CoverContentType(picture.Data); mime != "" {
+			metadata.Cover = picture.Data
+			metadata.CoverMIME = mime
+		}
+	}
+	return metadata, nil
+}
+
+// CoverContentType sniffs the type of an embedded picture and returns "" if
+// it is not an image covers can be served as. The MIME type in the tag is
+// ignored because the uploader picks it.
+func CoverContentType(data []byte) string {
+	mime := http.DetectContentType(data)
+	if !IsCoverContentType(mime) {
+		return ""
+	}
+	return mime
+}
+
+func IsCoverContentType(mime string) bool {
+	return slices.Contains(coverTypes, mime)
+}
+
+// splitArtists splits multi-value artist tags. ID3v2.4 separates values with
+// a NUL byte; taggers commonly use ";" or "/" as well.
+func splitArtists(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == 0 || r == ';' || r == '/'
+	})
+
+	var artists []string
+	for _, field := range fields {
+		if artist := strings.TrimSpace(field); artist != "" {
+			artists = append(artists, artist)
+		}
+	}
+	return artists
+}
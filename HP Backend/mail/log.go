@@ -0,0 +1,31 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LogMailer stands in for SMTP in development and tests. Bodies carry
+// single-use links, so only the recipient and subject are logged; the full
+// message is written to Dir when one is configured so links can be opened.
+type LogMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	slog.InfoContext(ctx, "email not sent, logged instead", "to", message.To, "subject", message.Subject)
+	if m.Dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, message), 0o600)
+}
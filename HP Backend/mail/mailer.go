@@ -0,0 +1,71 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+var ErrNoSMTPHost = errors.New("no SMTP host configured and dev mail is not enabled")
+
+// Config picks the mailer: SMTP when Host is set, otherwise Dev has to be set
+// and messages are only logged and, when Dir is set, written there as .eml
+// files.
+type Config struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	Dir      string
+	Dev      bool
+	Timeout  time.Duration
+}
+
+func New(config Config) (Mailer, error) {
+	if config.Host == "" {
+		if !config.Dev {
+			return nil, ErrNoSMTPHost
+		}
+		return &LogMailer{Dir: config.Dir, From: config.From}, nil
+	}
+	return &SMTPMailer{
+		Addr:     config.Host + ":" + config.Port,
+		Username: config.Username,
+		Password: config.Password,
+		From:     config.From,
+		Timeout:  config.Timeout,
+	}, nil
+}
+
+// format renders message as a plain text email. Header values are stripped
+// of line breaks so a crafted address or subject cannot add headers.
+func format(from string, message Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(message.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(message.Subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,178 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const secretLink = "https://houseparty.test/verify?token=secret-token"
+
+func TestNewRequiresSMTPOrDevMode(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   Mailer
+		err    error
+	}{
+		{name: "nothing set", config: Config{}, err: ErrNoSMTPHost},
+		{name: "only a mail dir", config: Config{Dir: "mail"}, err: ErrNoSMTPHost},
+		{name: "dev mode", config: Config{Dev: true, Dir: "mail"}, want: &LogMailer{Dir: "mail"}},
+		{name: "smtp", config: Config{Host: "smtp.test", Port: "587", Timeout: time.Second}, want: &SMTPMailer{Addr: "smtp.test:587", Timeout: time.Second}},
+		{name: "smtp wins over dev mode", config: Config{Host: "smtp.test", Port: "25", Dev: true}, want: &SMTPMailer{Addr: "smtp.test:25"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mailer, err := New(test.config)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error = %v, want %v", err, test.err)
+			}
+			if got, want := fmt.Sprintf("%#v", mailer), fmt.Sprintf("%#v", test.want); test.want != nil && got != want {
+				t.Errorf("mailer = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestLogMailerDoesNotLogBody(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	dir := t.TempDir()
+	mailer := &LogMailer{Dir: dir, From: "no-reply@houseparty.test"}
+	err := mailer.Send(context.Background(), Message{To: "alice@example.com", Subject: "Verify", Body: "Open " + secretLink})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(logs.String(), "secret-token") {
+		t.Errorf("log contains the token: %s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "alice@example.com") {
+		t.Errorf("log does not name the recipient: %s", logs.String())
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("mail dir has %d files (%v), want 1", len(files), err)
+	}
+	saved, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(saved, []byte(secretLink)) {
+		t.Error("saved message is missing the link")
+	}
+}
+
+// fakeSMTP answers just enough of the protocol for one message and returns
+// what was sent as DATA.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 fake ESMTP")
+		var data strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250 fake")
+			case command == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSMTPMailerSends(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	mailer := &SMTPMailer{Addr: addr, From: "no-reply@houseparty.test", Timeout: time.Second}
+
+	err := mailer.Send(context.Background(), Message{To: "alice@example.com\r\nBcc: eve@example.com", Subject: "Verify", Body: "Open " + secretLink})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := <-received
+	if !strings.Contains(data, secretLink) {
+		t.Errorf("message body is missing the link:\n%s", data)
+	}
+	if strings.Contains(data, "\r\nBcc:") {
+		t.Errorf("recipient added a header:\n%s", data)
+	}
+}
+
+func TestSMTPMailerTimesOutOnSilentServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		// Accept and then never greet the client.
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	mailer := &SMTPMailer{Addr: listener.Addr().String(), From: "no-reply@houseparty.test", Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	err = mailer.Send(context.Background(), Message{To: "alice@example.com", Subject: "Verify", Body: "hi"})
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("send took %v with a 100ms timeout", elapsed)
+	}
+}
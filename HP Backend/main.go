@@ -1,26 +1,92 @@
 package main
 
 import (
+	"context"
+	"log/slog"
+	"os"
+
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"houseparty.com/config"
 	"houseparty.com/controllers"
+	"houseparty.com/logging"
+	"houseparty.com/mail"
+	"houseparty.com/metrics"
 	"houseparty.com/middleware"
 	"houseparty.com/routes"
+	"houseparty.com/services"
 	"houseparty.com/storage"
+	"houseparty.com/tracing"
+	"houseparty.com/validation"
 	"houseparty.com/websockets"
 )
 
 func main() {
 	storage.InitDB()
 	config.LoadEnv()
+	logging.Init(config.GetLogLevel(), config.GetLogFormat())
+
+	if err := config.InitTokenEncryption(); err != nil {
+		slog.Error("invalid token encryption keys", "error", err)
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt-tokens" {
+		count, err := config.ReencryptTokens()
+		if err != nil {
+			slog.Error("could not re-encrypt tokens", "rewritten", count, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("re-encrypted spotify tokens", "rewritten", count)
+		return
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), config.GetTraceExporter())
+	if err != nil {
+		slog.Error("could not initialise tracing", "error", err)
+	} else {
+		defer shutdownTracing(context.Background())
+	}
 
 
+	if err := validation.Init(); err != nil {
+		slog.Error("could not register validation rules", "error", err)
+		os.Exit(1)
+	}
+
+	services.InitProviders(config.GetFakeProviderEnabled())
+	mailer, err := mail.New(config.GetMailConfig())
+	if err != nil {
+		slog.Error("could not set up mail, set SMTP_HOST or MAIL_DEV=true", "error", err)
+		os.Exit(1)
+	}
+	services.InitMailer(mailer)
+
 	manager := websockets.NewManager()
 	controllers.InitManager(manager)
+	metrics.RegisterEgressDepth(manager.EgressDepth)
+	go func() {
+		if err := metrics.Serve(config.GetMetricsAddr()); err != nil {
+			slog.Error("metrics server stopped", "addr", config.GetMetricsAddr(), "error", err)
+		}
+	}()
+
+	config.Tokens.ActiveUsers = manager.ActiveHostIDs
+	config.Tokens.OnRevoked = manager.NotifySpotifyRevoked
+	config.Tokens.Start(context.Background())
 	
 
-	server := gin.Default()
+	server := gin.New()
+	if err := server.SetTrustedProxies(config.GetTrustedProxies()); err != nil {
+		slog.Error("invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
+	server.Use(gin.Recovery())
+	server.Use(otelgin.Middleware(tracing.ServiceName))
+	server.Use(middleware.RequestID)
+	server.Use(middleware.RequestLogger)
 	server.Use(middleware.Cors())
+	server.Use(middleware.Metrics)
 	routes.RegisterRoutes(server)
 	server.Run(":8080")
 }
\ No newline at end of file
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "houseparty"
+
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by gin route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	WebsocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections",
+		Help:      "Number of currently open websocket connections.",
+	})
+
+	ActiveRooms = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_rooms",
+		Help:      "Number of rooms currently loaded by the websocket manager.",
+	})
+
+	EventsRouted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_events_total",
+		Help:      "Websocket events routed by type and outcome.",
+	}, []string{"type", "outcome"})
+
+	SpotifyRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "spotify_request_duration_seconds",
+		Help:      "Latency of Spotify API calls by operation and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "status"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Search and track cache lookups by cache and result.",
+	}, []string{"cache", "result"})
+
+	TokenRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spotify_token_refreshes_total",
+		Help:      "Spotify access token refreshes by result.",
+	}, []string{"result"})
+)
+
+// RegisterEgressDepth exposes the total number of events waiting in client
+// egress queues, computed on every scrape.
+func RegisterEgressDepth(depth func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_egress_queue_depth",
+		Help:      "Events waiting in websocket egress queues across all clients.",
+	}, func() float64 {
+		return float64(depth())
+	})
+}
+
+// Serve exposes /metrics on its own listener, away from the public API port,
+// so only the internal network can scrape it.
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return http.ListenAndServe(addr, mux)
+}
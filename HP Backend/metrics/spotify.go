@@ -0,0 +1,17 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ObserveSpotifyCall records the latency of a Spotify API call. A nil response
+// is recorded with the status "error".
+func ObserveSpotifyCall(operation string, start time.Time, resp *http.Response) {
+	status := "error"
+	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	SpotifyRequestDuration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+}
@@ -5,30 +5,60 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"houseparty.com/models"
+	"houseparty.com/services"
 	"houseparty.com/utils"
 )
 
 func Authenticate(context *gin.Context) {
 	token := context.Request.Header.Get("Authorization")
-
-	if token == "" {
-		token = context.Query("token")
-	}else{
-		token = strings.Replace(token, "Bearer ", "", 1)
-		token = strings.TrimSpace(token)
-	}
+	token = strings.Replace(token, "Bearer ", "", 1)
+	token = strings.TrimSpace(token)
 	
 	if token == "" {
 		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Token Not Found"})
 		return
 	}
 
-	userId, err := utils.VerifyToken(token)
+	claims, err := services.AuthenticateToken(token)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Token not Valid",  "error": err.Error()})
+		return
+	}
+
+	context.Set("userId", claims.UserId)
+	context.Set("sessionId", claims.SessionId)
+	context.Next()
+}
+
+// AuthenticateMedia lets audio and image elements load library files through
+// a signed URL, and falls back to the Authorization header otherwise.
+func AuthenticateMedia(context *gin.Context) {
+	if context.Request.Header.Get("Authorization") != "" {
+		Authenticate(context)
+		return
+	}
+
+	if !utils.VerifyMediaSignature(context.Request.URL.Path, context.Query("expires"), context.Query("sig")) {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Media link not valid"})
+		return
+	}
+	context.Next()
+}
+
+// RequireAdmin runs after Authenticate and turns away users without the admin
+// flag.
+func RequireAdmin(context *gin.Context) {
+	var user models.User
+	err := user.GetUserById(context.GetInt64("userId"))
 	if err != nil {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Token not Valid",  "error": err})
+		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Could not get user", "error": err.Error()})
 		return
 	}
 
-	context.Set("userId", userId)
+	if !user.IsAdmin {
+		context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Admin access required"})
+		return
+	}
 	context.Next()
-}
\ No newline at end of file
+}
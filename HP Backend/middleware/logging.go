@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"houseparty.com/logging"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+func RequestID(context *gin.Context) {
+	requestID := context.Request.Header.Get(requestIDHeader)
+	if requestID == "" || len(requestID) > 64 {
+		requestID = uuid.New().String()
+	}
+
+	logger := slog.Default().With("request_id", requestID)
+	context.Request = context.Request.WithContext(logging.WithLogger(context.Request.Context(), logger))
+	context.Set("requestId", requestID)
+	context.Header(requestIDHeader, requestID)
+
+	context.Next()
+}
+
+func RequestLogger(context *gin.Context) {
+	start := time.Now()
+	context.Next()
+
+	logger := logging.FromContext(context.Request.Context())
+	// Only the route template is logged: some paths carry secrets, such as
+	// the authorization code in the Spotify callback. Paths that match no
+	// route cannot be one of those.
+	attrs := []any{
+		"method", context.Request.Method,
+		"route", context.FullPath(),
+		"status", context.Writer.Status(),
+		"latency", time.Since(start),
+		"client_ip", context.ClientIP(),
+	}
+	if context.FullPath() == "" {
+		attrs = append(attrs, "path", context.Request.URL.Path)
+	}
+	if userId, ok := context.Get("userId"); ok {
+		attrs = append(attrs, "user_id", userId)
+	}
+
+	if len(context.Errors) > 0 {
+		logger.Error("request failed", append(attrs, "error", context.Errors.String())...)
+		return
+	}
+	logger.Info("request handled", attrs...)
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &logs
+}
+
+func TestRequestLoggerLeavesSecretsInPathOut(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := captureLogs(t)
+
+	server := gin.New()
+	server.Use(RequestID, RequestLogger)
+	server.POST("/spotify/token/callback/:code", func(context *gin.Context) {
+		context.Status(http.StatusOK)
+	})
+
+	request := httptest.NewRequest(http.MethodPost, "/spotify/token/callback/AQD-secret-auth-code", nil)
+	server.ServeHTTP(httptest.NewRecorder(), request)
+
+	if strings.Contains(logs.String(), "secret-auth-code") {
+		t.Errorf("authorization code was logged: %s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "route=/spotify/token/callback/:code") {
+		t.Errorf("route template was not logged: %s", logs.String())
+	}
+}
+
+func TestRequestLoggerLogsUnmatchedPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := captureLogs(t)
+
+	server := gin.New()
+	server.Use(RequestID, RequestLogger)
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/page", nil))
+
+	if !strings.Contains(logs.String(), "path=/no/such/page") || !strings.Contains(logs.String(), "status=404") {
+		t.Errorf("unmatched request was not logged with its path: %s", logs.String())
+	}
+}
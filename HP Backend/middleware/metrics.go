@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"houseparty.com/metrics"
+)
+
+func Metrics(context *gin.Context) {
+	start := time.Now()
+	context.Next()
+
+	route := context.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	status := strconv.Itoa(context.Writer.Status())
+	metrics.HTTPRequestDuration.WithLabelValues(context.Request.Method, route, status).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,36 @@
+package models
+
+type Device struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	IsActive      bool   `json:"is_active"`
+	VolumePercent *int   `json:"volume_percent"`
+}
+
+type PlaybackState struct {
+	DeviceID      string `json:"device_id"`
+	IsPlaying     bool   `json:"is_playing"`
+	TrackID       string `json:"track_id"`
+	TrackURI      string `json:"track_uri"`
+	LinkedFromID  string `json:"linked_from_id,omitempty"`
+	LinkedFromURI string `json:"linked_from_uri,omitempty"`
+	ProgressMs    int    `json:"progress_ms"`
+}
+
+// IsPlayingSong reports whether the device is on song. A relinked track
+// plays under a different id and uri, with the one that was asked for in
+// linked from.
+func (s *PlaybackState) IsPlayingSong(song *Song) bool {
+	for _, id := range []string{s.TrackID, s.LinkedFromID} {
+		if id != "" && id == song.Id {
+			return true
+		}
+	}
+	for _, uri := range []string{s.TrackURI, s.LinkedFromURI} {
+		if uri != "" && uri == song.URI {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package models
+
+import "testing"
+
+func TestPlaybackStateIsPlayingSong(t *testing.T) {
+	song := &Song{Id: "requested", URI: "spotify:track:requested"}
+	tests := []struct {
+		name  string
+		state PlaybackState
+		want  bool
+	}{
+		{name: "same track", state: PlaybackState{TrackID: "requested", TrackURI: "spotify:track:requested"}, want: true},
+		{name: "relinked track", state: PlaybackState{TrackID: "market", TrackURI: "spotify:track:market", LinkedFromID: "requested", LinkedFromURI: "spotify:track:requested"}, want: true},
+		{name: "matching uri only", state: PlaybackState{TrackURI: "spotify:track:requested"}, want: true},
+		{name: "other track", state: PlaybackState{TrackID: "other", TrackURI: "spotify:track:other"}, want: false},
+		{name: "nothing reported", state: PlaybackState{}, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.state.IsPlayingSong(song); got != test.want {
+				t.Errorf("IsPlayingSong = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	if (&PlaybackState{}).IsPlayingSong(&Song{}) {
+		t.Error("empty state matched a song without id or uri")
+	}
+}
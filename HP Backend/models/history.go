@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"houseparty.com/storage"
+)
+
+type HistoryEntry struct {
+	ID       int64     `json:"id"`
+	RoomID   string    `json:"room_id"`
+	Song     Song      `json:"song"`
+	PlayedAt time.Time `json:"played_at"`
+	Skipped  bool      `json:"skipped"`
+}
+
+func (e *HistoryEntry) Save() error {
+	song, err := json.Marshal(e.Song)
+	if err != nil {
+		return err
+	}
+
+	result, err := storage.DB.Exec(storage.SaveHistoryEntryQuery, e.RoomID, string(song), e.PlayedAt)
+	if err != nil {
+		return err
+	}
+
+	e.ID, _ = result.LastInsertId()
+	return nil
+}
+
+func (e *HistoryEntry) MarkSkipped() error {
+	_, err := storage.DB.Exec(storage.MarkHistoryEntrySkippedQuery, e.ID)
+	if err != nil {
+		return err
+	}
+
+	e.Skipped = true
+	return nil
+}
+
+// GetRoomHistory returns the songs played in a room, oldest first.
+func GetRoomHistory(roomId string, includeSkipped bool) ([]HistoryEntry, error) {
+	rows, err := storage.DB.Query(storage.RoomHistoryQuery, roomId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		var song string
+
+		err := rows.Scan(&entry.ID, &entry.RoomID, &song, &entry.PlayedAt, &entry.Skipped)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Skipped && !includeSkipped {
+			continue
+		}
+		if err := json.Unmarshal([]byte(song), &entry.Song); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func DeleteRoomHistory(roomId string) error {
+	_, err := storage.DB.Exec(storage.DeleteRoomHistoryQuery, roomId)
+	return err
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"houseparty.com/config"
+	"houseparty.com/library"
+	"houseparty.com/storage"
+	"houseparty.com/utils"
+)
+
+var (
+	ErrTrackNotFound        = errors.New("track not found")
+	ErrLibraryQuotaExceeded = errors.New("the track does not fit in your library, delete some tracks first")
+)
+
+type LocalTrack struct {
+	ID         string    `json:"id"`
+	OwnerID    int64     `json:"owner_id"`
+	Format     string    `json:"format"`
+	Title      string    `json:"title"`
+	Artists    []string  `json:"artists"`
+	Album      string    `json:"album"`
+	Year       int       `json:"year"`
+	DurationMs int       `json:"duration_ms"`
+	CoverMIME  string    `json:"cover_mime"`
+	Size       int64     `json:"size"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+type LocalTrackFilter struct {
+	Text     string
+	Artist   string
+	Album    string
+	YearFrom int
+	YearTo   int
+	Offset   int
+	Limit    int
+}
+
+// Save stores the track unless it would take the owner's library past quota
+// bytes.
+func (t *LocalTrack) Save(quota int64) error {
+	artists, err := json.Marshal(t.Artists)
+	if err != nil {
+		return err
+	}
+
+	result, err := storage.DB.Exec(storage.SaveLocalTrackQuery,
+		t.ID, t.OwnerID, t.Format, t.Title, string(artists), t.Album, t.Year, t.DurationMs, t.CoverMIME, t.Size, t.UploadedAt,
+		t.OwnerID, t.Size, quota,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrLibraryQuotaExceeded
+	}
+	return nil
+}
+
+func (t *LocalTrack) GetLocalTrackById(id string) error {
+	err := scanLocalTrack(storage.DB.QueryRow(storage.GetLocalTrackQuery, id), t)
+	if err == sql.ErrNoRows {
+		return ErrTrackNotFound
+	}
+	return err
+}
+
+func (t *LocalTrack) Delete() error {
+	_, err := storage.DB.Exec(storage.DeleteLocalTrackQuery, t.ID)
+	return err
+}
+
+// GetOwnedLocalTracks returns the id and format of every track the user
+// uploaded, enough to find the files on disk.
+func GetOwnedLocalTracks(ownerId int64) ([]LocalTrack, error) {
+	rows, err := storage.DB.Query(storage.OwnedLocalTracksQuery, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []LocalTrack
+	for rows.Next() {
+		var track LocalTrack
+		if err := rows.Scan(&track.ID, &track.Format); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, rows.Err()
+}
+
+func (t *LocalTrack) ToSong() Song {
+	song := Song{
+		Id:         t.ID,
+		URI:        "local:track:" + t.ID,
+		Name:       t.Title,
+		Artists:    t.Artists,
+		Album:      t.Album,
+		DurationMs: t.DurationMs,
+		StreamURL:  utils.SignMediaURL("/library/tracks/"+t.ID+"/stream", config.GetMediaURLTTL()),
+	}
+	if library.IsCoverContentType(t.CoverMIME) {
+		song.Image = Image{URL: utils.SignMediaURL("/library/tracks/"+t.ID+"/cover", config.GetMediaURLTTL())}
+	}
+	return song
+}
+
+// SearchLocalTracks returns a page of the owner's tracks matching filter and
+// the total number of matches.
+func SearchLocalTracks(ownerId int64, filter LocalTrackFilter) ([]LocalTrack, int, error) {
+	text := likePattern(filter.Text)
+	yearTo := filter.YearTo
+	if yearTo == 0 {
+		yearTo = 9999
+	}
+
+	rows, err := storage.DB.Query(storage.SearchLocalTracksQuery,
+		ownerId,
+		text, text, text,
+		likePattern(filter.Artist),
+		likePattern(filter.Album),
+		filter.YearFrom, yearTo,
+		filter.Limit, filter.Offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var tracks []LocalTrack
+	total := 0
+	for rows.Next() {
+		var track LocalTrack
+		if err := scanLocalTrack(rows, &track, &total); err != nil {
+			return nil, 0, err
+		}
+		tracks = append(tracks, track)
+	}
+
+	return tracks, total, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLocalTrack(row scanner, t *LocalTrack, extra ...any) error {
+	var artists string
+	dest := []any{&t.ID, &t.OwnerID, &t.Format, &t.Title, &artists, &t.Album, &t.Year, &t.DurationMs, &t.CoverMIME, &t.Size, &t.UploadedAt}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(artists), &t.Artists)
+}
+
+func likePattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(value) + "%"
+}
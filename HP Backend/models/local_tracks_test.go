@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"houseparty.com/storage"
+)
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	storage.OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	t.Cleanup(func() { storage.DB.Close() })
+}
+
+func TestSaveLocalTrackKeepsLibraryWithinQuota(t *testing.T) {
+	openTestDB(t)
+
+	const quota = 1000
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			track := LocalTrack{ID: fmt.Sprint("track-", i), OwnerID: 1, Format: "mp3", Artists: []string{}, Size: 300, UploadedAt: time.Now()}
+			errs[i] = track.Save(quota)
+		}()
+	}
+	wg.Wait()
+
+	saved := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			saved++
+		case !errors.Is(err, ErrLibraryQuotaExceeded):
+			t.Fatal(err)
+		}
+	}
+	if saved != 3 {
+		t.Errorf("saved %d tracks of 300 bytes within %d, want 3", saved, quota)
+	}
+
+	// Other users have their own quota.
+	other := LocalTrack{ID: "other", OwnerID: 2, Format: "mp3", Artists: []string{}, Size: 300, UploadedAt: time.Now()}
+	if err := other.Save(quota); err != nil {
+		t.Errorf("other user's upload: %v", err)
+	}
+}
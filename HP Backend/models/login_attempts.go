@@ -0,0 +1,92 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"houseparty.com/storage"
+)
+
+// LoginAttempts counts recent failed logins for one key, which is either an
+// account or a client address.
+type LoginAttempts struct {
+	Key         string
+	Failures    int
+	LockedUntil int64
+	LastFailure int64
+}
+
+func AccountLoginKey(userId int64) string {
+	return "account:" + strconv.FormatInt(userId, 10)
+}
+
+// Locked reports whether the key is locked and, if so, for how much longer.
+func (a *LoginAttempts) Locked(now time.Time) (time.Duration, bool) {
+	remaining := time.Unix(a.LockedUntil, 0).Sub(now)
+	return remaining, remaining > 0
+}
+
+// ReserveLoginAttempt counts an attempt against key as a failure before the
+// password is checked, and locks the key for delay(failures) straight away.
+// Doing both in one transaction means parallel requests cannot all get past
+// the lock while the first one is still hashing. When the key is already
+// locked nothing is counted and reserved is false. Failures older than window
+// are forgotten.
+func ReserveLoginAttempt(key string, now time.Time, window time.Duration, delay func(failures int) time.Duration) (attempts *LoginAttempts, reserved bool, err error) {
+	tx, err := storage.DB.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	cutoff := now.Add(-window).Unix()
+	if _, err := tx.Exec(storage.DeleteStaleLoginAttemptsQuery, cutoff, now.Unix()); err != nil {
+		return nil, false, err
+	}
+
+	attempts = &LoginAttempts{Key: key}
+	err = tx.QueryRow(storage.GetLoginAttemptsQuery, key).Scan(&attempts.Failures, &attempts.LockedUntil, &attempts.LastFailure)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, err
+	}
+	if _, locked := attempts.Locked(now); locked {
+		return attempts, false, nil
+	}
+
+	if attempts.LastFailure < cutoff {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailure = now.Unix()
+	attempts.LockedUntil = 0
+	if wait := delay(attempts.Failures); wait > 0 {
+		attempts.LockedUntil = unixCeil(now.Add(wait))
+	}
+
+	_, err = tx.Exec(storage.SaveLoginAttemptsQuery, key, attempts.Failures, attempts.LockedUntil, attempts.LastFailure)
+	if err != nil {
+		return nil, false, err
+	}
+	return attempts, true, tx.Commit()
+}
+
+// unixCeil rounds up to whole seconds, so storing a lock never shortens it.
+func unixCeil(t time.Time) int64 {
+	if t.Nanosecond() > 0 {
+		return t.Unix() + 1
+	}
+	return t.Unix()
+}
+
+// Refund takes back a reserved attempt that turned out not to be a failure,
+// along with the lock it set unless a later attempt has replaced it.
+func (a *LoginAttempts) Refund() error {
+	_, err := storage.DB.Exec(storage.RefundLoginAttemptQuery, a.LockedUntil, a.Key)
+	return err
+}
+
+func ClearLoginAttempts(key string) error {
+	_, err := storage.DB.Exec(storage.ClearLoginAttemptsQuery, key)
+	return err
+}
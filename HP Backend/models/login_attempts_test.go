@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserveLoginAttemptLocksForTheFullDelay(t *testing.T) {
+	openTestDB(t)
+	oneSecond := func(int) time.Duration { return time.Second }
+
+	// Just before a second boundary, where truncating would leave almost no lock.
+	now := time.Date(2024, 1, 1, 12, 0, 0, 999_000_000, time.UTC)
+	if _, reserved, err := ReserveLoginAttempt("address:test", now, time.Hour, oneSecond); err != nil || !reserved {
+		t.Fatalf("first attempt: reserved = %v, error = %v", reserved, err)
+	}
+
+	later := now.Add(time.Second - time.Millisecond)
+	attempts, reserved, err := ReserveLoginAttempt("address:test", later, time.Hour, oneSecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reserved {
+		t.Fatalf("attempt %v after a 1s lock was let through", later.Sub(now))
+	}
+	if remaining, _ := attempts.Locked(later); remaining <= 0 {
+		t.Errorf("remaining lock = %v, want more than 0", remaining)
+	}
+}
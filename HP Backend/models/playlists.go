@@ -0,0 +1,8 @@
+package models
+
+type Playlist struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	TrackCount int    `json:"track_count"`
+}
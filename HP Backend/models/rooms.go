@@ -7,13 +7,24 @@ import (
 	"houseparty.com/storage"
 )
 
+const (
+	PlaybackHost      = "host"
+	PlaybackListeners = "listeners"
+	PlaybackSpeaker   = "speaker"
+)
+
 type Room struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	HostID      int64     `json:"host_id"`
-	Public      bool      `json:"public"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID            string    `json:"id"`
+	Name          string    `json:"name" binding:"required,notblank,max=64"`
+	Description   string    `json:"description" binding:"max=500"`
+	HostID        int64     `json:"host_id"`
+	Public        bool      `json:"public"`
+	CreatedAt     time.Time `json:"created_at"`
+	Provider      string    `json:"provider"`
+	PlaybackMode  string    `json:"playback_mode" binding:"omitempty,oneof=host listeners speaker"`
+	BlockExplicit bool      `json:"block_explicit"`
+	PreviewClips  bool      `json:"preview_clips"`
+	DeviceID      string    `json:"device_id,omitempty"`
 }
 
 
@@ -40,7 +51,7 @@ func (r *Room) Delete() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return DeleteRoomHistory(r.ID)
 }
 
 func (r *Room) Save() error {
@@ -52,7 +63,7 @@ func (r *Room) Save() error {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(unique_id, r.Name, r.Description, r.HostID, r.Public, r.CreatedAt)
+	_, err = stmt.Exec(unique_id, r.Name, r.Description, r.HostID, r.Public, r.CreatedAt, r.Provider, r.PlaybackMode, r.BlockExplicit, r.PreviewClips)
 	if err != nil {
 		return err
 	}
@@ -68,9 +79,37 @@ func (r *Room) GetRoomById(id string) error {
 	}
 	defer stmt.Close()
 
-	err = stmt.QueryRow(id).Scan(&r.ID, &r.Name, &r.Description, &r.HostID, &r.Public, &r.CreatedAt)
+	err = stmt.QueryRow(id).Scan(&r.ID, &r.Name, &r.Description, &r.HostID, &r.Public, &r.CreatedAt, &r.Provider, &r.PlaybackMode, &r.BlockExplicit, &r.PreviewClips, &r.DeviceID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetHostedRoomIds(hostId int64) ([]string, error) {
+	rows, err := storage.DB.Query(storage.HostedRoomIdsQuery, hostId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *Room) SetDevice(deviceId string) error {
+	_, err := storage.DB.Exec(storage.UpdateRoomDeviceQuery, deviceId, r.ID)
 	if err != nil {
 		return err
 	}
+
+	r.DeviceID = deviceId
 	return nil
 }
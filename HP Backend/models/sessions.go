@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"houseparty.com/storage"
+)
+
+var (
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+)
+
+// Session is one login. Every access and refresh token issued for it carries
+// its id, so revoking the session logs that device out.
+type Session struct {
+	ID        string
+	UserID    int64
+	CreatedAt time.Time
+	RevokedAt sql.NullTime
+}
+
+// RefreshToken is stored by hash only; the raw token is handed to the client
+// once and never kept.
+type RefreshToken struct {
+	Hash      string
+	SessionID string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+func NewSession(userId int64) (*Session, error) {
+	session := &Session{
+		ID:        uuid.New().String(),
+		UserID:    userId,
+		CreatedAt: time.Now(),
+	}
+
+	_, err := storage.DB.Exec(storage.SaveSessionQuery, session.ID, session.UserID, session.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func GetSessionById(id string) (*Session, error) {
+	var session Session
+	err := storage.DB.QueryRow(storage.GetSessionQuery, id).Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *Session) Revoked() bool {
+	return s.RevokedAt.Valid
+}
+
+func (s *Session) Revoke() error {
+	_, err := storage.DB.Exec(storage.RevokeSessionQuery, time.Now(), s.ID)
+	return err
+}
+
+// RevokeUserSessions logs the user out everywhere.
+func RevokeUserSessions(userId int64) error {
+	_, err := storage.DB.Exec(storage.RevokeUserSessionsQuery, time.Now(), userId)
+	return err
+}
+
+// RevokeOtherSessions logs the user out everywhere except the current session.
+func RevokeOtherSessions(userId int64, keepSessionId string) error {
+	_, err := storage.DB.Exec(storage.RevokeOtherSessionsQuery, time.Now(), userId, keepSessionId)
+	return err
+}
+
+func (t *RefreshToken) Save() error {
+	_, err := storage.DB.Exec(storage.DeleteExpiredRefreshTokensQuery, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.DB.Exec(storage.SaveRefreshTokenQuery, t.Hash, t.SessionID, t.ExpiresAt)
+	return err
+}
+
+func GetRefreshToken(hash string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := storage.DB.QueryRow(storage.GetRefreshTokenQuery, hash).Scan(&token.Hash, &token.SessionID, &token.ExpiresAt, &token.UsedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed spends the token. It reports false when another request spent it
+// first.
+func (t *RefreshToken) MarkUsed() (bool, error) {
+	result, err := storage.DB.Exec(storage.MarkRefreshTokenUsedQuery, time.Now(), t.Hash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
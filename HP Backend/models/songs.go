@@ -5,11 +5,23 @@ type Song struct {
 	URI         string   `json:"uri"`
 	Name        string   `json:"name"`
 	Artists     []string `json:"artists"`
+	ArtistIds   []string `json:"artist_ids,omitempty"`
 	Album       string   `json:"album"`
+	AlbumId     string   `json:"album_id,omitempty"`
 	Image       Image    `json:"image"`
 	DurationMs  int      `json:"duration_ms"`
 	Explicit    bool     `json:"explicit"`
 	ExternalURL string   `json:"external_url"`
+	StreamURL   string   `json:"stream_url,omitempty"`
+	PreviewURL  string   `json:"preview_url,omitempty"`
+}
+
+// SongPage is one page of a longer list of songs, such as search results.
+type SongPage struct {
+	Songs  []Song `json:"songs"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+	Total  int    `json:"total"`
 }
 
 type Image struct {
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"houseparty.com/storage"
+	"houseparty.com/utils"
+)
+
+var ErrInvalidTicket = errors.New("room ticket is invalid or has expired")
+
+// RoomTicket lets one WebSocket upgrade into a room. It is stored by hash and
+// deleted the first time it is redeemed.
+type RoomTicket struct {
+	UserID    int64
+	SessionID string
+	RoomID    string
+	ExpiresAt int64
+}
+
+func (t *RoomTicket) Save(ticket string) error {
+	_, err := storage.DB.Exec(storage.DeleteExpiredRoomTicketsQuery, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.DB.Exec(storage.SaveRoomTicketQuery, utils.HashToken(ticket), t.UserID, t.SessionID, t.RoomID, t.ExpiresAt)
+	return err
+}
+
+// RedeemRoomTicket deletes the ticket and returns it if it was issued for
+// roomId and has not expired.
+func RedeemRoomTicket(ticket, roomId string) (*RoomTicket, error) {
+	if ticket == "" {
+		return nil, ErrInvalidTicket
+	}
+	hash := utils.HashToken(ticket)
+
+	var t RoomTicket
+	err := storage.DB.QueryRow(storage.GetRoomTicketQuery, hash).Scan(&t.UserID, &t.SessionID, &t.RoomID, &t.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidTicket
+	} else if err != nil {
+		return nil, err
+	}
+
+	result, err := storage.DB.Exec(storage.DeleteRoomTicketQuery, hash)
+	if err != nil {
+		return nil, err
+	}
+	if deleted, err := result.RowsAffected(); err != nil || deleted != 1 {
+		return nil, ErrInvalidTicket
+	}
+
+	if t.RoomID != roomId || time.Now().Unix() > t.ExpiresAt {
+		return nil, ErrInvalidTicket
+	}
+	return &t, nil
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"houseparty.com/storage"
+	"houseparty.com/utils"
+)
+
+const (
+	TokenVerifyEmail   = "verify-email"
+	TokenResetPassword = "reset-password"
+)
+
+var ErrInvalidUserToken = errors.New("link is invalid or has expired")
+
+// UserToken backs the links sent by email. Only the hash is stored, a user
+// has at most one live token per purpose, and each token works once.
+type UserToken struct {
+	UserID    int64
+	Purpose   string
+	ExpiresAt int64
+}
+
+func (t *UserToken) Save(token string) error {
+	_, err := storage.DB.Exec(storage.DeleteExpiredUserTokensQuery, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.DB.Exec(storage.DeleteUserTokensQuery, t.UserID, t.Purpose)
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.DB.Exec(storage.SaveUserTokenQuery, utils.HashToken(token), t.UserID, t.Purpose, t.ExpiresAt)
+	return err
+}
+
+// ConsumeUserToken deletes the token and returns it if it was issued for
+// purpose and has not expired.
+func ConsumeUserToken(token, purpose string) (*UserToken, error) {
+	if token == "" {
+		return nil, ErrInvalidUserToken
+	}
+	hash := utils.HashToken(token)
+
+	var t UserToken
+	err := storage.DB.QueryRow(storage.GetUserTokenQuery, hash).Scan(&t.UserID, &t.Purpose, &t.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidUserToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	result, err := storage.DB.Exec(storage.DeleteUserTokenQuery, hash)
+	if err != nil {
+		return nil, err
+	}
+	if deleted, err := result.RowsAffected(); err != nil || deleted != 1 {
+		return nil, ErrInvalidUserToken
+	}
+
+	if t.Purpose != purpose || time.Now().Unix() > t.ExpiresAt {
+		return nil, ErrInvalidUserToken
+	}
+	return &t, nil
+}
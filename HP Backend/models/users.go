@@ -8,12 +8,17 @@ import (
 	"houseparty.com/utils"
 )
 
+var ErrUserNotFound = errors.New("user not found")
+
 type User struct {
 	Id               int64  `json:"id"`
-	Username         string `json:"username"`
-	Email            string `json:"email"`
-	Password         string `json:"password"`
+	Username         string `json:"username" binding:"required,min=3,max=32,username,notreserved"`
+	Email            string `json:"email" binding:"required,max=254,email"`
+	Password         string `json:"password" binding:"required,min=8,max=72,password"`
 	SpotifyConnected bool   `json:"spotify_connected"`
+	EmailVerified    bool   `json:"email_verified"`
+	IsAdmin          bool   `json:"-"`
+	SpotifyID        string `json:"-"`
 }
 
 type UserResponse struct {
@@ -21,6 +26,8 @@ type UserResponse struct {
 	Username         string `json:"username"`
 	Email            string `json:"email"`
 	SpotifyConnected bool   `json:"spotify_connected"`
+	EmailVerified    bool   `json:"email_verified"`
+	IsAdmin          bool   `json:"is_admin"`
 }
 
 func (u *User) Save() error {
@@ -45,13 +52,38 @@ func (u *User) Save() error {
 	return nil
 }
 
+// SaveFromSpotify creates a user linked to a Spotify account, without a
+// password.
+func (u *User) SaveFromSpotify(spotifyId string) error {
+	result, err := storage.DB.Exec(storage.SaveSpotifyUserQuery, u.Email, u.Username, spotifyId)
+	if err != nil {
+		return err
+	}
+
+	u.Id, _ = result.LastInsertId()
+	u.SpotifyID = spotifyId
+	return nil
+}
+
 func (u *User) GetUserById(id int64) error {
-	row := storage.DB.QueryRow(storage.GetUserByIdQuery, id)
+	return u.load(storage.GetUserByIdQuery, id)
+}
 
-	err := row.Scan(&u.Id, &u.Username, &u.Email, &u.SpotifyConnected)
+func (u *User) GetUserByEmail(email string) error {
+	return u.load(storage.GetUserByEmailQuery, email)
+}
+
+func (u *User) GetUserBySpotifyId(spotifyId string) error {
+	return u.load(storage.GetUserBySpotifyIdQuery, spotifyId)
+}
+
+func (u *User) load(query string, arg any) error {
+	row := storage.DB.QueryRow(query, arg)
+
+	err := row.Scan(&u.Id, &u.Username, &u.Email, &u.SpotifyConnected, &u.EmailVerified, &u.IsAdmin, &u.SpotifyID)
 
 	if err == sql.ErrNoRows {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	} else if err != nil {
 		return err
 	}
@@ -59,6 +91,67 @@ func (u *User) GetUserById(id int64) error {
 	return nil
 }
 
+func (u *User) LinkSpotify(spotifyId string) error {
+	_, err := storage.DB.Exec(storage.LinkSpotifyQuery, spotifyId, u.Id)
+	if err != nil {
+		return err
+	}
+
+	u.SpotifyID = spotifyId
+	return nil
+}
+
+func (u *User) UpdatePassword(password string) error {
+	hashPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.DB.Exec(storage.UpdatePasswordQuery, hashPassword, u.Id)
+	return err
+}
+
+func (u *User) PasswordHash() (string, error) {
+	var hashPassword string
+	err := storage.DB.QueryRow(storage.GetPasswordHashQuery, u.Id).Scan(&hashPassword)
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	return hashPassword, err
+}
+
+func (u *User) UpdateProfile() error {
+	_, err := storage.DB.Exec(storage.UpdateProfileQuery, u.Username, u.Email, u.EmailVerified, u.Id)
+	return err
+}
+
+// Delete removes the user together with their rooms, library entries,
+// sessions and tokens. Files on disk are left to the caller.
+func (u *User) Delete() error {
+	tx, err := storage.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range storage.DeleteUserQueries {
+		if _, err := tx.Exec(query, u.Id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (u *User) VerifyEmail() error {
+	_, err := storage.DB.Exec(storage.VerifyEmailQuery, u.Id)
+	if err != nil {
+		return err
+	}
+
+	u.EmailVerified = true
+	return nil
+}
+
 func (u *User) RetrieveHashPassword() (string, error) {
 	row := storage.DB.QueryRow(storage.RetrieveHashPasswordQuery, u.Email, u.Username)
 
@@ -66,7 +159,7 @@ func (u *User) RetrieveHashPassword() (string, error) {
 	err := row.Scan(&u.Id, &u.Username, &u.Email, &HashPassword)
 
 	if err == sql.ErrNoRows {
-		return "", errors.New("there is no user with these credentials")
+		return "", ErrUserNotFound
 	} else if err != nil {
 		return "", err
 	}
@@ -80,6 +173,8 @@ func (u *User) ToUserResponse() *UserResponse {
 		Username: u.Username,
 		Email:    u.Email,
 		SpotifyConnected: u.SpotifyConnected,
+		EmailVerified: u.EmailVerified,
+		IsAdmin: u.IsAdmin,
 	}
 }
 
@@ -88,7 +183,7 @@ func (u *User) ActivateSpotify() error {
 	err := row.Err()
 
 	if err == sql.ErrNoRows {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	} else if err != nil {
 		return err
 	}
@@ -12,13 +12,39 @@ func RegisterRoutes(server *gin.Engine){
 
 	authenticated.POST("/room/create",controllers.CreateNewRoom )
 	authenticated.GET("/rooms",controllers.RetieveRooms )
-	authenticated.GET("/join/room/:id",controllers.JoinRoom )
 	authenticated.DELETE("/room/delete/:id", controllers.DeleteRoom)
+	authenticated.POST("/room/:id/import", controllers.ImportSongs)
+	authenticated.POST("/room/:id/export", controllers.ExportRoomHistory)
+	authenticated.POST("/room/:id/ticket", controllers.IssueRoomTicket)
 	authenticated.GET("/auth/token", controllers.SpotifyAuthToken)
 	authenticated.POST("/spotify/token/callback/:code", controllers.SpotifyTokenCallBack)
+	authenticated.GET("/spotify/status", controllers.SpotifyConnectionStatus)
+	authenticated.GET("/cache/stats", middleware.RequireAdmin, controllers.GetCacheStats)
+	authenticated.DELETE("/cache/search", middleware.RequireAdmin, controllers.ClearSearchCache)
+	authenticated.DELETE("/cache/tracks/:provider", middleware.RequireAdmin, controllers.InvalidateTrackCache)
+	authenticated.DELETE("/cache/tracks/:provider/:id", middleware.RequireAdmin, controllers.InvalidateTrackCache)
+	authenticated.POST("/library/upload", controllers.UploadLocalTrack)
+	authenticated.GET("/library", controllers.ListLocalTracks)
+	authenticated.DELETE("/library/tracks/:id", controllers.DeleteLocalTrack)
+	authenticated.POST("/logout", controllers.Logout)
+	authenticated.POST("/email/verify/resend", controllers.ResendVerificationEmail)
+	authenticated.GET("/me", controllers.GetProfile)
+	authenticated.PATCH("/me", controllers.UpdateProfile)
+	authenticated.PUT("/me/password", controllers.ChangePassword)
+	authenticated.DELETE("/me/spotify", controllers.DisconnectSpotify)
+	authenticated.DELETE("/me", controllers.DeleteAccount)
+	authenticated.POST("/admin/users/:id/unlock", middleware.RequireAdmin, controllers.UnlockUser)
 	
 	server.POST("/signup", controllers.SignUp)
 	server.POST("/login", controllers.Login)
-	server.GET("/get/token", controllers.TestGetToken)
+	server.POST("/token/refresh", controllers.RefreshToken)
+	server.GET("/auth/spotify/login", controllers.SpotifyLoginURL)
+	server.POST("/auth/spotify/login/callback", controllers.SpotifyLogin)
+	server.POST("/email/verify", controllers.VerifyEmail)
+	server.POST("/password/forgot", controllers.ForgotPassword)
+	server.POST("/password/reset", controllers.ResetPassword)
+	server.GET("/join/room/:id", controllers.JoinRoom)
+	server.GET("/library/tracks/:id/stream", middleware.AuthenticateMedia, controllers.StreamLocalTrack)
+	server.GET("/library/tracks/:id/cover", middleware.AuthenticateMedia, controllers.LocalTrackCover)
 
 }
\ No newline at end of file
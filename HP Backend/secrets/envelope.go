@@ -0,0 +1,178 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	prefix  = "enc:v1:"
+	keySize = 32
+)
+
+var ErrUnknownKey = errors.New("value was encrypted with an unknown key")
+
+// Keyring holds the key encryption keys. Values are sealed with a fresh data
+// key which is itself sealed with the primary key, so rotating the primary
+// only requires re-wrapping, and older keys stay available for decryption.
+//
+// A nil Keyring stores values in plaintext.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// ParseKeyring reads "id:base64key,id:base64key". The first entry is the
+// primary key used for new values; every key must be 32 bytes.
+func ParseKeyring(spec string) (*Keyring, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	keyring := &Keyring{keys: make(map[string][]byte)}
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key entry %q, expected id:base64key", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64: %w", id, err)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %q must be %d bytes, got %d", id, keySize, len(key))
+		}
+		if _, exists := keyring.keys[id]; exists {
+			return nil, fmt.Errorf("key %q is listed twice", id)
+		}
+
+		keyring.keys[id] = key
+		if keyring.primary == "" {
+			keyring.primary = id
+		}
+	}
+
+	return keyring, nil
+}
+
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if k == nil {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := seal(k.keys[k.primary], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.primary + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt opens a value produced by Encrypt. Values without the envelope
+// prefix are returned unchanged so rows written before encryption was
+// enabled keep working until they are re-encrypted.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if k == nil {
+		return "", errors.New("value is encrypted but no encryption keys are configured")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	key, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, parts[0])
+	}
+
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+
+	dataKey, err := open(key, wrappedKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value is plaintext or sealed with a key other
+// than the current primary.
+func (k *Keyring) NeedsRotation(value string) bool {
+	if k == nil {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	return !strings.HasPrefix(value, prefix+k.primary+":")
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
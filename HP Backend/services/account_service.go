@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"houseparty.com/config"
+	"houseparty.com/logging"
+	"houseparty.com/mail"
+	"houseparty.com/models"
+	"houseparty.com/utils"
+)
+
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+var ErrEmailAlreadyVerified = errors.New("email is already verified")
+
+var mailer mail.Mailer = &mail.LogMailer{}
+
+func InitMailer(m mail.Mailer) {
+	mailer = m
+}
+
+const verifyEmailBody = `Hi %s,
+
+Confirm this is your email address by opening the link below. It expires in 24 hours.
+
+%s
+
+If you did not sign up for House Party you can ignore this email.
+`
+
+const resetPasswordBody = `Hi %s,
+
+Someone asked to reset the password for your House Party account. Open the link below to choose a new one. It expires in an hour and works once.
+
+%s
+
+If this was not you, ignore this email and your password will stay the same.
+`
+
+func SendVerificationEmail(ctx context.Context, user *models.User) error {
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	link, err := issueEmailLink(user.Id, models.TokenVerifyEmail, verifyEmailTTL, "/verify-email")
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your House Party email",
+		Body:    fmt.Sprintf(verifyEmailBody, user.Username, link),
+	})
+}
+
+// sendVerificationInBackground mails a new user without making sign up wait
+// on, or fail because of, the mail server.
+func sendVerificationInBackground(ctx context.Context, user models.User) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := SendVerificationEmail(ctx, &user); err != nil {
+			logging.FromContext(ctx).Error("could not send verification email", "user_id", user.Id, "error", err)
+		}
+	}()
+}
+
+func VerifyEmail(token string) (*models.User, error) {
+	userToken, err := models.ConsumeUserToken(token, models.TokenVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := user.GetUserById(userToken.UserID); err != nil {
+		return nil, err
+	}
+	return &user, user.VerifyEmail()
+}
+
+// RequestPasswordReset mails a reset link when email belongs to an account.
+// The work happens after the request returns, so neither the response nor
+// its timing shows whether the account exists.
+func RequestPasswordReset(ctx context.Context, email string) {
+	go requestPasswordReset(context.WithoutCancel(ctx), email)
+}
+
+func requestPasswordReset(ctx context.Context, email string) {
+	logger := logging.FromContext(ctx)
+
+	var user models.User
+	err := user.GetUserByEmail(email)
+	if errors.Is(err, models.ErrUserNotFound) {
+		logger.Info("password reset requested for unknown email")
+		return
+	} else if err != nil {
+		logger.Error("could not look up user for password reset", "error", err)
+		return
+	}
+
+	link, err := issueEmailLink(user.Id, models.TokenResetPassword, resetPasswordTTL, "/reset-password")
+	if err != nil {
+		logger.Error("could not create password reset token", "user_id", user.Id, "error", err)
+		return
+	}
+
+	err = mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your House Party password",
+		Body:    fmt.Sprintf(resetPasswordBody, user.Username, link),
+	})
+	if err != nil {
+		logger.Error("could not send password reset email", "user_id", user.Id, "error", err)
+	}
+}
+
+// ResetPassword sets a new password and logs the user out everywhere. The
+// link arrived by email, so it also proves the address is real.
+func ResetPassword(token, password string) error {
+	userToken, err := models.ConsumeUserToken(token, models.TokenResetPassword)
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := user.GetUserById(userToken.UserID); err != nil {
+		return err
+	}
+	if err := user.UpdatePassword(password); err != nil {
+		return err
+	}
+	if err := models.RevokeUserSessions(user.Id); err != nil {
+		return err
+	}
+
+	if !user.EmailVerified {
+		return user.VerifyEmail()
+	}
+	return nil
+}
+
+func issueEmailLink(userId int64, purpose string, ttl time.Duration, path string) (string, error) {
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	userToken := models.UserToken{
+		UserID:    userId,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}
+	if err := userToken.Save(token); err != nil {
+		return "", err
+	}
+
+	return config.GetFrontendURL() + path + "?token=" + url.QueryEscape(token), nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"houseparty.com/mail"
+	"houseparty.com/models"
+	"houseparty.com/storage"
+)
+
+// linkToken pulls the token out of the link in an email.
+func linkToken(t *testing.T, message mail.Message) string {
+	t.Helper()
+	for _, field := range strings.Fields(message.Body) {
+		link, err := url.Parse(field)
+		if err == nil && link.Query().Get("token") != "" {
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("no link with a token in:\n%s", message.Body)
+	return ""
+}
+
+func verificationToken(t *testing.T, recorder *recordingMailer, user *models.User) string {
+	t.Helper()
+	if err := SendVerificationEmail(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return linkToken(t, recorder.next(t))
+}
+
+func assertEmailVerified(t *testing.T, userId int64, want bool) {
+	t.Helper()
+	var user models.User
+	if err := user.GetUserById(userId); err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerified != want {
+		t.Errorf("email verified = %v, want %v", user.EmailVerified, want)
+	}
+}
+
+func TestVerifyEmailWorksOnce(t *testing.T) {
+	openTestDB(t)
+	recorder := useRecordingMailer(t)
+	user := createTestUser(t, "nina", "correct-horse1")
+	token := verificationToken(t, recorder, user)
+
+	verified, err := VerifyEmail(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Id != user.Id || !verified.EmailVerified {
+		t.Errorf("verified = %+v, want user %d verified", verified, user.Id)
+	}
+
+	if _, err := VerifyEmail(token); !errors.Is(err, models.ErrInvalidUserToken) {
+		t.Errorf("reusing the link = %v, want %v", err, models.ErrInvalidUserToken)
+	}
+}
+
+func TestVerifyEmailRejectsExpiredToken(t *testing.T) {
+	openTestDB(t)
+	recorder := useRecordingMailer(t)
+	user := createTestUser(t, "omar", "correct-horse1")
+	token := verificationToken(t, recorder, user)
+
+	_, err := storage.DB.Exec(`UPDATE user_tokens SET expires_at = ? WHERE user_id = ?`, time.Now().Add(-time.Minute).Unix(), user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := VerifyEmail(token); !errors.Is(err, models.ErrInvalidUserToken) {
+		t.Errorf("expired link = %v, want %v", err, models.ErrInvalidUserToken)
+	}
+	assertEmailVerified(t, user.Id, false)
+}
+
+func TestVerifyEmailRejectsResetToken(t *testing.T) {
+	openTestDB(t)
+	recorder := useRecordingMailer(t)
+	user := createTestUser(t, "pia", "correct-horse1")
+
+	requestPasswordReset(context.Background(), user.Email)
+	token := linkToken(t, recorder.next(t))
+
+	if _, err := VerifyEmail(token); !errors.Is(err, models.ErrInvalidUserToken) {
+		t.Errorf("password reset link used to verify = %v, want %v", err, models.ErrInvalidUserToken)
+	}
+	assertEmailVerified(t, user.Id, false)
+}
+
+func TestResendVerificationToVerifiedAccount(t *testing.T) {
+	openTestDB(t)
+	recorder := useRecordingMailer(t)
+	user := createTestUser(t, "quinn", "correct-horse1")
+	verifyTestEmail(t, user)
+
+	if err := SendVerificationEmail(context.Background(), user); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Fatalf("resend = %v, want %v", err, ErrEmailAlreadyVerified)
+	}
+	select {
+	case message := <-recorder.sent:
+		t.Errorf("an email was sent to %s", message.To)
+	default:
+	}
+}
+
+func TestUpdateProfileEmailNeedsVerifyingAgain(t *testing.T) {
+	openTestDB(t)
+	recorder := useRecordingMailer(t)
+	user := createTestUser(t, "rosa", "correct-horse1")
+	verifyTestEmail(t, user)
+
+	username := "rosa2"
+	if _, err := UpdateProfile(context.Background(), user.Id, ProfileUpdate{Username: &username}); err != nil {
+		t.Fatal(err)
+	}
+	assertEmailVerified(t, user.Id, true)
+
+	email := "rosa.new@example.com"
+	updated, err := UpdateProfile(context.Background(), user.Id, ProfileUpdate{Email: &email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.EmailVerified {
+		t.Error("changed email is still marked verified")
+	}
+	assertEmailVerified(t, user.Id, false)
+
+	message := recorder.next(t)
+	if message.To != email {
+		t.Errorf("verification sent to %q, want %q", message.To, email)
+	}
+	if _, err := VerifyEmail(linkToken(t, message)); err != nil {
+		t.Fatal(err)
+	}
+	assertEmailVerified(t, user.Id, true)
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"houseparty.com/config"
+	"houseparty.com/models"
+	"houseparty.com/utils"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or has expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrSessionRevoked      = errors.New("session has been revoked")
+)
+
+type AuthTokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+func StartSession(user *models.User) (*AuthTokens, error) {
+	session, err := models.NewSession(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	return issueTokens(user, session.ID)
+}
+
+// RefreshSession trades a refresh token for a new access and refresh token.
+// Refresh tokens are single use: presenting one a second time means it was
+// copied, so the whole session is revoked.
+func RefreshSession(refreshToken string) (*AuthTokens, error) {
+	stored, err := models.GetRefreshToken(utils.HashToken(refreshToken))
+	if errors.Is(err, models.ErrRefreshTokenNotFound) {
+		return nil, ErrInvalidRefreshToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	session, err := models.GetSessionById(stored.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reuse is checked first so a copied token still revokes the session once
+	// it has expired or the session was already logged out.
+	spent, err := stored.MarkUsed()
+	if err != nil {
+		return nil, err
+	}
+	if stored.UsedAt.Valid || !spent {
+		if err := session.Revoke(); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if session.Revoked() {
+		return nil, ErrSessionRevoked
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var user models.User
+	if err := user.GetUserById(session.UserID); err != nil {
+		return nil, err
+	}
+	return issueTokens(&user, session.ID)
+}
+
+func Logout(sessionId string) error {
+	session, err := models.GetSessionById(sessionId)
+	if err != nil {
+		return err
+	}
+	return session.Revoke()
+}
+
+// AuthenticateToken verifies an access token and that its session has not
+// been logged out.
+func AuthenticateToken(token string) (*utils.AccessClaims, error) {
+	claims, err := utils.VerifyToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := models.GetSessionById(claims.SessionId)
+	if errors.Is(err, models.ErrSessionNotFound) {
+		return nil, ErrSessionRevoked
+	} else if err != nil {
+		return nil, err
+	}
+	if session.Revoked() || session.UserID != claims.UserId {
+		return nil, ErrSessionRevoked
+	}
+
+	return claims, nil
+}
+
+func issueTokens(user *models.User, sessionId string) (*AuthTokens, error) {
+	expiresAt := time.Now().Add(config.GetAccessTokenTTL())
+	accessToken, err := utils.GenerateToken(user.Email, user.Username, user.Id, sessionId, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	stored := models.RefreshToken{
+		Hash:      utils.HashToken(refreshToken),
+		SessionID: sessionId,
+		ExpiresAt: time.Now().Add(config.GetRefreshTokenTTL()),
+	}
+	if err := stored.Save(); err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresAt: expiresAt.Unix()}, nil
+}
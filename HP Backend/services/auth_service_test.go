@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"houseparty.com/storage"
+	"houseparty.com/utils"
+)
+
+func expireRefreshToken(t *testing.T, refreshToken string) {
+	t.Helper()
+	_, err := storage.DB.Exec(`UPDATE refresh_tokens SET expires_at = ? WHERE token_hash = ?`, time.Now().Add(-time.Minute), utils.HashToken(refreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshSessionDetectsReuseOfExpiredToken(t *testing.T) {
+	openTestDB(t)
+	user := createTestUser(t, "carol", "correct-horse1")
+
+	first, err := StartSession(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RefreshSession(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The copied token is replayed after it would have expired anyway.
+	expireRefreshToken(t, first.RefreshToken)
+	if _, err := RefreshSession(first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replaying an expired token = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if _, err := RefreshSession(second.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("refresh after reuse = %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestRefreshSessionRejectsExpiredToken(t *testing.T) {
+	openTestDB(t)
+	user := createTestUser(t, "dave", "correct-horse1")
+
+	tokens, err := StartSession(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expireRefreshToken(t, tokens.RefreshToken)
+
+	if _, err := RefreshSession(tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expired token = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	claims, err := utils.VerifyToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AuthenticateToken(tokens.AccessToken); err != nil {
+		t.Errorf("session %s was revoked for a token that was only expired: %v", claims.SessionId, err)
+	}
+}
@@ -0,0 +1,18 @@
+package services
+
+import (
+	"context"
+
+	"houseparty.com/models"
+)
+
+// Browser is implemented by providers that can list the songs of an artist or
+// album, using the ids carried on models.Song.
+type Browser interface {
+	ArtistTopTracks(ctx context.Context, artistId string, userId int64) ([]models.Song, error)
+	AlbumTracks(ctx context.Context, albumId string, offset, limit int, userId int64) (*models.SongPage, error)
+}
+
+func BrowserFor(provider MusicProvider) (Browser, bool) {
+	return capability[Browser](provider)
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"houseparty.com/models"
+)
+
+// FakeProvider serves songs from an in-memory catalogue so rooms can be run
+// without network access or a Spotify account.
+type FakeProvider struct {
+	sync.RWMutex
+	songs  []models.Song
+	tokens map[int64]string
+	issued int
+}
+
+func NewFakeProvider(songs ...models.Song) *FakeProvider {
+	return &FakeProvider{
+		songs:  songs,
+		tokens: make(map[int64]string),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) AddSongs(songs ...models.Song) {
+	p.Lock()
+	defer p.Unlock()
+	p.songs = append(p.songs, songs...)
+}
+
+func (p *FakeProvider) SearchSongs(ctx context.Context, query SearchQuery, userId int64) (*models.SongPage, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	var matches []models.Song
+	for _, song := range p.songs {
+		if fakeSongMatches(song, query) {
+			matches = append(matches, song)
+		}
+	}
+
+	page := &models.SongPage{Offset: query.Offset, Limit: query.Limit, Total: len(matches)}
+	if query.Offset < len(matches) {
+		end := min(query.Offset+query.Limit, len(matches))
+		page.Songs = matches[query.Offset:end]
+	}
+	return page, nil
+}
+
+func fakeSongMatches(song models.Song, query SearchQuery) bool {
+	artists := strings.ToLower(strings.Join(song.Artists, " "))
+	text := strings.ToLower(query.Text)
+
+	if text != "" && !strings.Contains(strings.ToLower(song.Name), text) &&
+		!strings.Contains(strings.ToLower(song.Album), text) && !strings.Contains(artists, text) {
+		return false
+	}
+	if query.Artist != "" && !strings.Contains(artists, strings.ToLower(query.Artist)) {
+		return false
+	}
+	if query.Album != "" && !strings.Contains(strings.ToLower(song.Album), strings.ToLower(query.Album)) {
+		return false
+	}
+	return true
+}
+
+func (p *FakeProvider) GetSongById(ctx context.Context, id string, userId int64) (*models.Song, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	for _, song := range p.songs {
+		if song.Id == id {
+			return &song, nil
+		}
+	}
+	return nil, errors.New("song not found")
+}
+
+func (p *FakeProvider) ExchangeCode(ctx context.Context, code, codeVerifier string, userId int64) error {
+	if code == "" {
+		return errors.New("code is empty")
+	}
+	_, err := p.RefreshToken(ctx, userId)
+	return err
+}
+
+func (p *FakeProvider) AccessToken(ctx context.Context, userId int64) (string, error) {
+	p.RLock()
+	token, ok := p.tokens[userId]
+	p.RUnlock()
+
+	if ok {
+		return token, nil
+	}
+	return p.RefreshToken(ctx, userId)
+}
+
+func (p *FakeProvider) RefreshToken(ctx context.Context, userId int64) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.issued++
+	token := fmt.Sprintf("fake-token-%d-%d", userId, p.issued)
+	p.tokens[userId] = token
+	return token, nil
+}
+
+func defaultFakeCatalogue() []models.Song {
+	return []models.Song{
+		fakeSong("fake-1", "Midnight Drive", []string{"The Placeholders"}, "Night Tests", 201000),
+		fakeSong("fake-2", "Offline Anthem", []string{"Mock Orchestra"}, "No Network", 184000),
+		fakeSong("fake-3", "Stub Me Tender", []string{"Elvis Fixture"}, "Unit Tests", 167000),
+		fakeSong("fake-4", "Local Loop", []string{"The Placeholders", "Mock Orchestra"}, "Night Tests", 226000),
+		fakeSong("fake-5", "Green Build", []string{"CI Collective"}, "Pipelines", 195000),
+		fakeSong("fake-6", "Race Condition", []string{"CI Collective"}, "Pipelines", 142000),
+	}
+}
+
+func fakeSong(id, name string, artists []string, album string, durationMs int) models.Song {
+	return models.Song{
+		Id:         id,
+		URI:        "fake:track:" + id,
+		Name:       name,
+		Artists:    artists,
+		Album:      album,
+		DurationMs: durationMs,
+	}
+}
+
+// ImportTracks imports every catalogue song from the album named by a
+// fake:album:<name> link, or a single fake:track:<id>.
+func (p *FakeProvider) ImportTracks(ctx context.Context, link string, userId int64, onPage func(page ImportPage) error) error {
+	if id, ok := strings.CutPrefix(link, "fake:track:"); ok {
+		song, err := p.GetSongById(ctx, id, userId)
+		if err != nil {
+			return err
+		}
+		return onPage(ImportPage{Songs: []models.Song{*song}, Total: 1})
+	}
+
+	album, ok := strings.CutPrefix(link, "fake:album:")
+	if !ok {
+		return ErrUnsupportedLink
+	}
+
+	p.RLock()
+	var songs []models.Song
+	for _, song := range p.songs {
+		if strings.EqualFold(song.Album, album) {
+			songs = append(songs, song)
+		}
+	}
+	p.RUnlock()
+
+	return onPage(ImportPage{Songs: songs, Total: len(songs)})
+}
+
+func (p *FakeProvider) ResolveLink(ctx context.Context, raw string) (*ResolvedLink, error) {
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) != 3 || parts[0] != "fake" || parts[2] == "" {
+		return nil, ErrUnsupportedLink
+	}
+
+	switch parts[1] {
+	case LinkTrack, LinkAlbum:
+		return &ResolvedLink{Kind: parts[1], ID: parts[2], Link: raw}, nil
+	default:
+		return nil, ErrUnsupportedLink
+	}
+}
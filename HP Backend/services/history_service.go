@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"houseparty.com/models"
+)
+
+var (
+	ErrRoomAccessDenied  = errors.New("you do not have access to this room")
+	ErrNothingToExport   = errors.New("no songs have been played in this room yet")
+	ErrExportUnsupported = errors.New("this room's music provider cannot export playlists")
+)
+
+type ExportOptions struct {
+	Name           string `json:"name" binding:"max=100"`
+	Public         bool   `json:"public"`
+	ExcludeSkipped bool   `json:"exclude_skipped"`
+}
+
+// ExportRoomHistory saves the songs played in a room, in play order, as a
+// playlist on the requesting user's own account.
+func ExportRoomHistory(ctx context.Context, roomId string, userId int64, options ExportOptions) (*models.Playlist, error) {
+	var room models.Room
+	err := room.GetRoomById(roomId)
+	if err != nil {
+		return nil, err
+	}
+	if !room.Public && room.HostID != userId {
+		return nil, ErrRoomAccessDenied
+	}
+
+	provider, err := GetProvider(room.Provider)
+	if err != nil {
+		return nil, err
+	}
+	exporter, ok := PlaylistExporterFor(provider)
+	if !ok {
+		return nil, ErrExportUnsupported
+	}
+
+	entries, err := models.GetRoomHistory(roomId, !options.ExcludeSkipped)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrNothingToExport
+	}
+
+	songs := make([]models.Song, 0, len(entries))
+	for _, entry := range entries {
+		songs = append(songs, entry.Song)
+	}
+
+	if options.Name == "" {
+		options.Name = fmt.Sprintf("%s (%s)", room.Name, entries[0].PlayedAt.Format("2 Jan 2006"))
+	}
+	description := fmt.Sprintf("Songs played in the HouseParty room %q, exported %s.", room.Name, time.Now().Format("2 Jan 2006"))
+
+	return exporter.CreatePlaylist(ctx, userId, options.Name, description, options.Public, songs)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"houseparty.com/models"
+)
+
+// exportingProvider records the playlist it was asked to create.
+type exportingProvider struct {
+	*FakeProvider
+	userId int64
+	name   string
+	songs  []models.Song
+}
+
+func (p *exportingProvider) CreatePlaylist(ctx context.Context, userId int64, name, description string, public bool, songs []models.Song) (*models.Playlist, error) {
+	p.userId = userId
+	p.name = name
+	p.songs = songs
+	return &models.Playlist{}, nil
+}
+
+func saveTestRoom(t *testing.T, hostId int64, public bool) *models.Room {
+	t.Helper()
+	room := &models.Room{Name: "Friday", HostID: hostId, Public: public, CreatedAt: time.Now(), Provider: "fake", PlaybackMode: models.PlaybackHost}
+	if err := room.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return room
+}
+
+func playTestSong(t *testing.T, roomId, songId string, playedAt time.Time, skipped bool) {
+	t.Helper()
+	entry := models.HistoryEntry{RoomID: roomId, Song: models.Song{Id: songId}, PlayedAt: playedAt}
+	if err := entry.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if skipped {
+		if err := entry.MarkSkipped(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func songIds(songs []models.Song) []string {
+	ids := make([]string, len(songs))
+	for i, song := range songs {
+		ids[i] = song.Id
+	}
+	return ids
+}
+
+func TestExportRoomHistory(t *testing.T) {
+	openTestDB(t)
+	exporter := &exportingProvider{FakeProvider: NewFakeProvider()}
+	registerTestProvider(t, exporter)
+
+	host := createTestUser(t, "sam", "correct-horse1")
+	room := saveTestRoom(t, host.Id, false)
+	start := time.Now().Add(-time.Hour)
+	// Saved out of order; the playlist follows the time each song played.
+	playTestSong(t, room.ID, "second", start.Add(4*time.Minute), true)
+	playTestSong(t, room.ID, "first", start, false)
+	playTestSong(t, room.ID, "third", start.Add(8*time.Minute), false)
+
+	if _, err := ExportRoomHistory(context.Background(), room.ID, host.Id, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := songIds(exporter.songs); len(got) != 3 || got[0] != "first" || got[1] != "second" || got[2] != "third" {
+		t.Errorf("exported %v, want first, second, third", got)
+	}
+	if exporter.userId != host.Id || exporter.name == "" {
+		t.Errorf("playlist %q created for user %d, want a named playlist for %d", exporter.name, exporter.userId, host.Id)
+	}
+
+	if _, err := ExportRoomHistory(context.Background(), room.ID, host.Id, ExportOptions{Name: "Last night", ExcludeSkipped: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := songIds(exporter.songs); len(got) != 2 || got[0] != "first" || got[1] != "third" {
+		t.Errorf("exported %v without skipped songs, want first, third", got)
+	}
+	if exporter.name != "Last night" {
+		t.Errorf("playlist name = %q, want Last night", exporter.name)
+	}
+}
+
+func TestExportRoomHistoryRefusals(t *testing.T) {
+	openTestDB(t)
+	registerTestProvider(t, &exportingProvider{FakeProvider: NewFakeProvider()})
+	host := createTestUser(t, "tess", "correct-horse1")
+	guest := createTestUser(t, "uma", "correct-horse1")
+
+	private := saveTestRoom(t, host.Id, false)
+	playTestSong(t, private.ID, "song", time.Now(), false)
+	if _, err := ExportRoomHistory(context.Background(), private.ID, guest.Id, ExportOptions{}); !errors.Is(err, ErrRoomAccessDenied) {
+		t.Errorf("guest exporting a private room = %v, want %v", err, ErrRoomAccessDenied)
+	}
+
+	public := saveTestRoom(t, host.Id, true)
+	if _, err := ExportRoomHistory(context.Background(), public.ID, guest.Id, ExportOptions{}); !errors.Is(err, ErrNothingToExport) {
+		t.Errorf("exporting an empty room = %v, want %v", err, ErrNothingToExport)
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"houseparty.com/config"
+	"houseparty.com/library"
+	"houseparty.com/models"
+)
+
+const maxLibraryPage = 100
+
+var (
+	ErrUnreadableAudio = errors.New("could not read the audio file")
+	ErrTrackNotOwned   = errors.New("the track belongs to another user")
+
+	localLibrary *LocalProvider
+)
+
+// UploadLocalTrack stores an uploaded audio file in the host's library. The
+// file is written to a temporary name first so a failed upload never leaves
+// a track without its audio behind.
+func UploadLocalTrack(ownerId int64, fileName string, file io.Reader) (*models.LocalTrack, error) {
+	temp, err := os.CreateTemp(localLibrary.Dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	size, err := io.Copy(temp, file)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := library.Read(temp, size)
+	if errors.Is(err, library.ErrUnsupportedFormat) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadableAudio, err)
+	}
+
+	track := &models.LocalTrack{
+		ID:         uuid.New().String(),
+		OwnerID:    ownerId,
+		Format:     metadata.Format,
+		Title:      metadata.Title,
+		Artists:    metadata.Artists,
+		Album:      metadata.Album,
+		Year:       metadata.Year,
+		DurationMs: int(metadata.Duration.Milliseconds()),
+		CoverMIME:  metadata.CoverMIME,
+		Size:       size,
+		UploadedAt: time.Now(),
+	}
+	if track.Title == "" {
+		track.Title = strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	}
+	if track.Artists == nil {
+		track.Artists = []string{}
+	}
+
+	if len(metadata.Cover) > 0 {
+		if err := os.WriteFile(coverPath(track.ID), metadata.Cover, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := temp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(temp.Name(), audioPath(track)); err != nil {
+		os.Remove(coverPath(track.ID))
+		return nil, err
+	}
+
+	if err := track.Save(config.GetLibraryQuota()); err != nil {
+		os.Remove(audioPath(track))
+		os.Remove(coverPath(track.ID))
+		return nil, err
+	}
+	return track, nil
+}
+
+func ListLocalTracks(ownerId int64, search string, offset, limit int) ([]models.Song, int, error) {
+	if limit <= 0 || limit > maxLibraryPage {
+		limit = maxLibraryPage
+	}
+
+	tracks, total, err := models.SearchLocalTracks(ownerId, models.LocalTrackFilter{Text: search, Offset: max(offset, 0), Limit: limit})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	songs := make([]models.Song, 0, len(tracks))
+	for i := range tracks {
+		songs = append(songs, tracks[i].ToSong())
+	}
+	return songs, total, nil
+}
+
+// OpenLocalTrack opens a track's audio for streaming; the caller closes it.
+func OpenLocalTrack(id string) (*models.LocalTrack, *os.File, error) {
+	var track models.LocalTrack
+	err := track.GetLocalTrackById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(audioPath(&track))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &track, file, nil
+}
+
+func OpenLocalCover(id string) (*models.LocalTrack, *os.File, error) {
+	var track models.LocalTrack
+	err := track.GetLocalTrackById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Covers stored before their type was sniffed may not be images.
+	if !library.IsCoverContentType(track.CoverMIME) {
+		return nil, nil, os.ErrNotExist
+	}
+
+	file, err := os.Open(coverPath(track.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &track, file, nil
+}
+
+func DeleteLocalTrack(id string, userId int64) error {
+	var track models.LocalTrack
+	err := track.GetLocalTrackById(id)
+	if err != nil {
+		return err
+	}
+	if track.OwnerID != userId {
+		return ErrTrackNotOwned
+	}
+
+	if err := track.Delete(); err != nil {
+		return err
+	}
+	os.Remove(audioPath(&track))
+	os.Remove(coverPath(track.ID))
+	return nil
+}
+
+func audioPath(track *models.LocalTrack) string {
+	return filepath.Join(localLibrary.Dir, track.ID+"."+track.Format)
+}
+
+func coverPath(id string) string {
+	return filepath.Join(localLibrary.Dir, id+".cover")
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	LinkTrack    = "track"
+	LinkAlbum    = "album"
+	LinkPlaylist = "playlist"
+)
+
+// ResolvedLink is a pasted share link reduced to what it points at. Link is a
+// canonical form that TrackImporter accepts.
+type ResolvedLink struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	Link string `json:"link"`
+}
+
+type LinkResolver interface {
+	ResolveLink(ctx context.Context, raw string) (*ResolvedLink, error)
+}
+
+func ResolveLink(ctx context.Context, provider MusicProvider, raw string) (*ResolvedLink, error) {
+	resolver, ok := capability[LinkResolver](provider)
+	if !ok {
+		return nil, ErrUnsupportedLink
+	}
+	return resolver.ResolveLink(ctx, strings.TrimSpace(raw))
+}
+
+// LooksLikeLink tells pasted links apart from plain track ids.
+func LooksLikeLink(value string) bool {
+	return strings.ContainsAny(value, ":/")
+}
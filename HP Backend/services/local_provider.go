@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"houseparty.com/models"
+)
+
+var ErrNoAccounts = errors.New("the local library does not use accounts")
+
+// LocalProvider serves audio files uploaded by hosts. Searches only cover the
+// room host's own uploads, and files are streamed by this server, so there
+// are no tokens involved.
+type LocalProvider struct {
+	Dir string
+}
+
+func NewLocalProvider(dir string) *LocalProvider {
+	return &LocalProvider{Dir: dir}
+}
+
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+func (p *LocalProvider) SearchSongs(ctx context.Context, query SearchQuery, userId int64) (*models.SongPage, error) {
+	filter := models.LocalTrackFilter{
+		Text:   query.Text,
+		Artist: query.Artist,
+		Album:  query.Album,
+		Offset: query.Offset,
+		Limit:  query.Limit,
+	}
+	if query.Year != "" {
+		from, to, _ := strings.Cut(query.Year, "-")
+		filter.YearFrom, _ = strconv.Atoi(from)
+		filter.YearTo = filter.YearFrom
+		if to != "" {
+			filter.YearTo, _ = strconv.Atoi(to)
+		}
+	}
+
+	tracks, total, err := models.SearchLocalTracks(userId, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.SongPage{Songs: []models.Song{}, Offset: query.Offset, Limit: query.Limit, Total: total}
+	for i := range tracks {
+		page.Songs = append(page.Songs, tracks[i].ToSong())
+	}
+	return page, nil
+}
+
+func (p *LocalProvider) GetSongById(ctx context.Context, id string, userId int64) (*models.Song, error) {
+	var track models.LocalTrack
+	err := track.GetLocalTrackById(id)
+	if err != nil {
+		return nil, err
+	}
+	if track.OwnerID != userId {
+		return nil, models.ErrTrackNotFound
+	}
+
+	song := track.ToSong()
+	return &song, nil
+}
+
+func (p *LocalProvider) ExchangeCode(ctx context.Context, code, codeVerifier string, userId int64) error {
+	return ErrNoAccounts
+}
+
+func (p *LocalProvider) AccessToken(ctx context.Context, userId int64) (string, error) {
+	return "", nil
+}
+
+func (p *LocalProvider) RefreshToken(ctx context.Context, userId int64) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"houseparty.com/logging"
+	"houseparty.com/models"
+	"houseparty.com/utils"
+)
+
+// loginFailureWindow is how long a failed login counts against an account or
+// address before it is forgotten.
+const loginFailureWindow = time.Hour
+
+var ErrInvalidCredentials = errors.New("invalid login or password")
+
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// loginPolicy allows a few failures for free, then doubles the wait after
+// each further one until the key is locked out for the full duration.
+type loginPolicy struct {
+	FreeAttempts    int
+	LockoutAttempts int
+	BaseDelay       time.Duration
+	Lockout         time.Duration
+}
+
+var (
+	accountLoginPolicy = loginPolicy{FreeAttempts: 3, LockoutAttempts: 10, BaseDelay: time.Second, Lockout: 15 * time.Minute}
+	// Addresses get more room because several people can share one.
+	addressLoginPolicy = loginPolicy{FreeAttempts: 10, LockoutAttempts: 50, BaseDelay: time.Second, Lockout: time.Hour}
+)
+
+func (p loginPolicy) delay(failures int) time.Duration {
+	if failures < p.FreeAttempts {
+		return 0
+	}
+	if failures >= p.LockoutAttempts {
+		return p.Lockout
+	}
+	return min(p.BaseDelay<<(failures-p.FreeAttempts), p.Lockout)
+}
+
+func addressLoginKey(clientAddress string) string {
+	return "address:" + clientAddress
+}
+
+// unknownLoginKey throttles guesses against logins that match no account the
+// same way as real accounts, so a lockout does not reveal which ones exist.
+func unknownLoginKey(user *models.User) string {
+	login := user.Email
+	if login == "" {
+		login = user.Username
+	}
+	return "login:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+// reserveLoginAttempt counts an attempt against key before the password is
+// checked, or returns a LoginThrottledError if the key is locked.
+func reserveLoginAttempt(ctx context.Context, key string, policy loginPolicy, now time.Time) (*models.LoginAttempts, error) {
+	attempts, reserved, err := models.ReserveLoginAttempt(key, now, loginFailureWindow, policy.delay)
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		remaining, _ := attempts.Locked(now)
+		return nil, &LoginThrottledError{RetryAfter: remaining}
+	}
+
+	if attempts.Failures == policy.LockoutAttempts {
+		logging.FromContext(ctx).Warn("login locked out", "key", key, "failures", attempts.Failures, "until", time.Unix(attempts.LockedUntil, 0))
+	}
+	return attempts, nil
+}
+
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashPassword("not a real password")
+	return hash
+})
+
+// UnlockAccount clears the failed logins recorded against a user so they can
+// sign in again straight away.
+func UnlockAccount(userId int64) error {
+	var user models.User
+	if err := user.GetUserById(userId); err != nil {
+		return err
+	}
+	return models.ClearLoginAttempts(models.AccountLoginKey(userId))
+}
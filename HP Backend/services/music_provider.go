@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"houseparty.com/cache"
+	"houseparty.com/config"
+	"houseparty.com/httpclient"
+	"houseparty.com/models"
+	"houseparty.com/spotify"
+)
+
+const DefaultProvider = "spotify"
+
+// ErrProviderUnavailable wraps failures that are expected to clear up on
+// their own: rate limiting, upstream outages or an open circuit breaker.
+var ErrProviderUnavailable = errors.New("music provider temporarily unavailable")
+
+// MusicProvider is the boundary between the room engine and a music catalogue.
+// Token methods are keyed by our user id; providers without accounts may
+// return an empty token.
+type MusicProvider interface {
+	Name() string
+	SearchSongs(ctx context.Context, query SearchQuery, userId int64) (*models.SongPage, error)
+	GetSongById(ctx context.Context, id string, userId int64) (*models.Song, error)
+	ExchangeCode(ctx context.Context, code, codeVerifier string, userId int64) error
+	AccessToken(ctx context.Context, userId int64) (string, error)
+	RefreshToken(ctx context.Context, userId int64) (string, error)
+}
+
+var (
+	providers     = make(map[string]MusicProvider)
+	providersLock sync.RWMutex
+)
+
+func RegisterProvider(provider MusicProvider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[provider.Name()] = provider
+}
+
+func GetProvider(name string) (MusicProvider, error) {
+	if name == "" {
+		name = DefaultProvider
+	}
+
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown music provider %q", name)
+	}
+	return provider, nil
+}
+
+func InitProviders(enableFake bool) {
+	searchCache = cache.NewLRU[string, models.SongPage](config.GetSearchCacheSize(), config.GetSearchCacheTTL())
+
+	market := config.GetSpotifyMarket()
+	spotifyAPI = spotify.NewClient(config.GetSpotifyAPIURL(), httpclient.Shared)
+	spotifyProvider := NewSpotifyProvider(spotifyAPI, market)
+	RegisterProvider(NewCachingProvider(spotifyProvider, market))
+
+	localLibrary = NewLocalProvider(config.GetLibraryDir())
+	if err := os.MkdirAll(localLibrary.Dir, 0o755); err != nil {
+		slog.Error("could not create local library directory", "dir", localLibrary.Dir, "error", err)
+	}
+	RegisterProvider(localLibrary)
+	if enableFake {
+		RegisterProvider(NewFakeProvider(defaultFakeCatalogue()...))
+	}
+}
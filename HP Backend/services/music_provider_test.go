@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"houseparty.com/models"
+)
+
+func registerTestProvider(t *testing.T, provider MusicProvider) {
+	t.Helper()
+	providersLock.Lock()
+	previous, existed := providers[provider.Name()]
+	providersLock.Unlock()
+
+	RegisterProvider(provider)
+	t.Cleanup(func() {
+		providersLock.Lock()
+		defer providersLock.Unlock()
+		if existed {
+			providers[provider.Name()] = previous
+		} else {
+			delete(providers, provider.Name())
+		}
+	})
+}
+
+func TestRegistryServesFakeProvider(t *testing.T) {
+	fake := NewFakeProvider(defaultFakeCatalogue()...)
+	registerTestProvider(t, fake)
+
+	provider, err := GetProvider("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider != fake {
+		t.Fatalf("GetProvider returned %T, want the registered fake", provider)
+	}
+
+	page, err := provider.SearchSongs(context.Background(), SearchQuery{Text: "pipelines", Limit: 10}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || len(page.Songs) != 2 {
+		t.Errorf("search found %d of %d songs, want 2", len(page.Songs), page.Total)
+	}
+
+	song, err := provider.GetSongById(context.Background(), "fake-3", 1)
+	if err != nil || song.Name != "Stub Me Tender" {
+		t.Errorf("GetSongById = %v, %v", song, err)
+	}
+}
+
+func TestRegistryReplacesProviderWithSameName(t *testing.T) {
+	registerTestProvider(t, NewFakeProvider())
+	replacement := NewFakeProvider(models.Song{Id: "only"})
+	RegisterProvider(replacement)
+
+	provider, err := GetProvider("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider != replacement {
+		t.Error("the later registration did not replace the first")
+	}
+}
+
+func TestRegistryRejectsUnknownProvider(t *testing.T) {
+	if provider, err := GetProvider("not-a-provider"); err == nil {
+		t.Fatalf("GetProvider returned %T for an unknown name", provider)
+	}
+}
+
+func TestCapabilitiesAreFoundThroughCache(t *testing.T) {
+	cached := NewCachingProvider(NewFakeProvider(defaultFakeCatalogue()...), "GB")
+
+	if _, ok := TrackImporterFor(cached); !ok {
+		t.Error("track importer of the wrapped fake was not found")
+	}
+	if _, ok := PlaybackControllerFor(cached); ok {
+		t.Error("fake provider reported speaker playback it does not have")
+	}
+
+	resolved, err := ResolveLink(context.Background(), cached, "fake:album:Pipelines")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Kind != LinkAlbum || resolved.ID != "Pipelines" {
+		t.Errorf("resolved = %+v, want the Pipelines album", resolved)
+	}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+
+	"houseparty.com/models"
+)
+
+// PlaybackController is implemented by providers that can drive playback on
+// a user's own device from the server, as used by speaker mode rooms.
+type PlaybackController interface {
+	ListDevices(ctx context.Context, userId int64) ([]models.Device, error)
+	StartPlayback(ctx context.Context, userId int64, deviceId string, song *models.Song, positionMs int) error
+	PausePlayback(ctx context.Context, userId int64, deviceId string) error
+	PlaybackState(ctx context.Context, userId int64) (*models.PlaybackState, error)
+}
+
+type unwrapper interface {
+	Unwrap() MusicProvider
+}
+
+func PlaybackControllerFor(provider MusicProvider) (PlaybackController, bool) {
+	return capability[PlaybackController](provider)
+}
+
+// capability looks through provider decorators such as the cache for an
+// implementation of an optional provider interface.
+func capability[T any](provider MusicProvider) (T, bool) {
+	for provider != nil {
+		if implementation, ok := provider.(T); ok {
+			return implementation, true
+		}
+		wrapped, ok := provider.(unwrapper)
+		if !ok {
+			break
+		}
+		provider = wrapped.Unwrap()
+	}
+
+	var none T
+	return none, false
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"houseparty.com/models"
+)
+
+var ErrMissingScope = errors.New("the connected account has not granted the required permission, reconnect to grant it")
+
+// PlaylistExporter is implemented by providers that can save songs as a
+// playlist on a user's own account.
+type PlaylistExporter interface {
+	CreatePlaylist(ctx context.Context, userId int64, name, description string, public bool, songs []models.Song) (*models.Playlist, error)
+}
+
+func PlaylistExporterFor(provider MusicProvider) (PlaylistExporter, bool) {
+	return capability[PlaylistExporter](provider)
+}
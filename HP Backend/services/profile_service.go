@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"houseparty.com/logging"
+	"houseparty.com/models"
+	"houseparty.com/utils"
+)
+
+var (
+	ErrWrongPassword  = errors.New("current password is incorrect")
+	ErrInvalidProfile = errors.New("username and email cannot be empty")
+	ErrNoPassword     = errors.New("this account signs in with Spotify and has no password, request a password reset to set one")
+)
+
+// ProfileUpdate holds the fields a user wants to change; nil fields are left
+// as they are.
+type ProfileUpdate struct {
+	Username *string `json:"username" binding:"omitempty,min=3,max=32,username,notreserved"`
+	Email    *string `json:"email" binding:"omitempty,max=254,email"`
+}
+
+// UpdateProfile changes the username and email. A new email address has to
+// be verified again.
+func UpdateProfile(ctx context.Context, userId int64, update ProfileUpdate) (*models.User, error) {
+	var user models.User
+	if err := user.GetUserById(userId); err != nil {
+		return nil, err
+	}
+
+	username := changedField(update.Username, user.Username)
+	email := changedField(update.Email, user.Email)
+	if (username != nil && *username == "") || (email != nil && *email == "") {
+		return nil, ErrInvalidProfile
+	}
+	if username == nil && email == nil {
+		return &user, nil
+	}
+
+	if err := checkInUse(valueOrEmpty(username), valueOrEmpty(email)); err != nil {
+		return nil, err
+	}
+
+	if username != nil {
+		user.Username = *username
+	}
+	if email != nil {
+		user.Email = *email
+		user.EmailVerified = false
+	}
+	if err := user.UpdateProfile(); err != nil {
+		return nil, err
+	}
+
+	if email != nil {
+		sendVerificationInBackground(ctx, user)
+	}
+	return &user, nil
+}
+
+// ChangePassword sets a new password once the current one is confirmed, and
+// logs out every other session.
+func ChangePassword(userId int64, sessionId, currentPassword, newPassword string) error {
+	user := models.User{Id: userId}
+	if err := confirmPassword(&user, currentPassword); err != nil {
+		return err
+	}
+
+	if err := user.UpdatePassword(newPassword); err != nil {
+		return err
+	}
+	return models.RevokeOtherSessions(userId, sessionId)
+}
+
+// DeleteAccount removes the user and everything they own once their password
+// is confirmed. It returns the ids of the rooms they hosted so open
+// connections to them can be closed.
+func DeleteAccount(ctx context.Context, userId int64, password string) ([]string, error) {
+	user := models.User{Id: userId}
+	if err := confirmPassword(&user, password); err != nil {
+		return nil, err
+	}
+
+	roomIds, err := models.GetHostedRoomIds(userId)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := models.GetOwnedLocalTracks(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.Delete(); err != nil {
+		return nil, err
+	}
+
+	for i := range tracks {
+		if err := os.Remove(audioPath(&tracks[i])); err != nil && !os.IsNotExist(err) {
+			logging.FromContext(ctx).Warn("could not remove library file", "track_id", tracks[i].ID, "error", err)
+		}
+		os.Remove(coverPath(tracks[i].ID))
+	}
+	return roomIds, nil
+}
+
+func confirmPassword(user *models.User, password string) error {
+	hashPassword, err := user.PasswordHash()
+	if err != nil {
+		return err
+	}
+	if hashPassword == "" {
+		return ErrNoPassword
+	}
+	if !utils.CheckPasswordHash(password, hashPassword) {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// changedField returns the trimmed new value, or nil when it is missing or
+// the same as the current one.
+func changedField(value *string, current string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == current {
+		return nil
+	}
+	return &trimmed
+}
+
+func valueOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
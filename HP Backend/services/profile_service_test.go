@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"houseparty.com/config"
+	"houseparty.com/models"
+	"houseparty.com/spotify"
+	"houseparty.com/utils"
+)
+
+func sessionOf(t *testing.T, tokens *AuthTokens) string {
+	t.Helper()
+	stored, err := models.GetRefreshToken(utils.HashToken(tokens.RefreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored.SessionID
+}
+
+func TestUpdateProfileRejectsTakenAndBlankFields(t *testing.T) {
+	openTestDB(t)
+	user := createTestUser(t, "vera", "correct-horse1")
+	createTestUser(t, "walt", "correct-horse1")
+
+	taken := "walt"
+	takenEmail := "walt@example.com"
+	blank := "   "
+	tests := []struct {
+		name   string
+		update ProfileUpdate
+		err    error
+	}{
+		{name: "taken username", update: ProfileUpdate{Username: &taken}, err: ErrUsernameInUse},
+		{name: "taken email", update: ProfileUpdate{Email: &takenEmail}, err: ErrEmailInUse},
+		{name: "blank username", update: ProfileUpdate{Username: &blank}, err: ErrInvalidProfile},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := UpdateProfile(context.Background(), user.Id, test.update); !errors.Is(err, test.err) {
+				t.Errorf("error = %v, want %v", err, test.err)
+			}
+		})
+	}
+
+	var stored models.User
+	if err := stored.GetUserById(user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Username != "vera" || stored.Email != "vera@example.com" {
+		t.Errorf("profile changed to %s <%s> by rejected updates", stored.Username, stored.Email)
+	}
+}
+
+func TestChangePasswordLogsOutOtherSessions(t *testing.T) {
+	openTestDB(t)
+	user := createTestUser(t, "xena", "correct-horse1")
+	current, err := StartSession(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := StartSession(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ChangePassword(user.Id, sessionOf(t, current), "wrong-horse1", "battery-staple2"); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("wrong current password = %v, want %v", err, ErrWrongPassword)
+	}
+	if err := ChangePassword(user.Id, sessionOf(t, current), "correct-horse1", "battery-staple2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := confirmPassword(&models.User{Id: user.Id}, "battery-staple2"); err != nil {
+		t.Errorf("new password is not accepted: %v", err)
+	}
+	if _, err := RefreshSession(current.RefreshToken); err != nil {
+		t.Errorf("the session that changed the password was logged out: %v", err)
+	}
+	if _, err := RefreshSession(other.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("other session refresh = %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestDeleteAccountRemovesRoomsAndTokens(t *testing.T) {
+	openTestDB(t)
+	fakeSpotify(t, spotify.User{ID: "spotify-yara"})
+	user := createTestUser(t, "yara", "correct-horse1")
+	if _, err := ConnectSpotify(context.Background(), user, "code", "verifier"); err != nil {
+		t.Fatal(err)
+	}
+	room := saveTestRoom(t, user.Id, true)
+
+	if _, err := DeleteAccount(context.Background(), user.Id, "wrong-horse1"); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("wrong password = %v, want %v", err, ErrWrongPassword)
+	}
+
+	roomIds, err := DeleteAccount(context.Background(), user.Id, "correct-horse1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roomIds) != 1 || roomIds[0] != room.ID {
+		t.Errorf("hosted rooms = %v, want [%s]", roomIds, room.ID)
+	}
+
+	var deleted models.User
+	if err := deleted.GetUserById(user.Id); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("user lookup after deleting = %v, want %v", err, models.ErrUserNotFound)
+	}
+	var deletedRoom models.Room
+	if err := deletedRoom.GetRoomById(room.ID); err == nil {
+		t.Error("hosted room is still there")
+	}
+	if _, err := config.GetTokenFromDB(user.Id); !errors.Is(err, config.ErrTokenNotFound) {
+		t.Errorf("spotify token lookup after deleting = %v, want %v", err, config.ErrTokenNotFound)
+	}
+}
@@ -2,17 +2,42 @@ package services
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"houseparty.com/models"
 	"houseparty.com/storage"
+	"houseparty.com/validation"
 )
 
 func CreateRoom(room *models.Room, userId int64) error {
+	room.Name = strings.TrimSpace(room.Name)
+	room.Description = strings.TrimSpace(room.Description)
+	if room.Provider == "" {
+		room.Provider = DefaultProvider
+	}
+	provider, err := GetProvider(room.Provider)
+	if err != nil {
+		return validation.Errors{"provider": fmt.Sprintf("%q is not available", room.Provider)}
+	}
+
+	switch room.PlaybackMode {
+	case "":
+		room.PlaybackMode = models.PlaybackHost
+	case models.PlaybackHost, models.PlaybackListeners:
+	case models.PlaybackSpeaker:
+		if _, ok := PlaybackControllerFor(provider); !ok {
+			return validation.Errors{"playback_mode": fmt.Sprintf("speaker is not supported by %s", provider.Name())}
+		}
+	default:
+		return validation.Errors{"playback_mode": "must be one of host, listeners, speaker"}
+	}
+
 	room.HostID = userId
 	room.CreatedAt = time.Now()
-	err := room.Save()
+	err = room.Save()
 	return err
 }
 
@@ -97,6 +122,10 @@ func  GetUserRoom(userId int64) (*models.RoomResponse, error) {
 		&room.HostID, 
 		&room.Public, 
 		&room.CreatedAt,
+		&room.Provider,
+		&room.PlaybackMode,
+		&room.BlockExplicit,
+		&room.PreviewClips,
 		&room.HostName)
 	
 	if err == sql.ErrNoRows{
@@ -127,6 +156,10 @@ func FilterAndGetRooms(userId int64) ([]*models.RoomResponse, error) {
 			&room.HostID, 
 			&room.Public, 
 			&room.CreatedAt, 
+			&room.Provider,
+			&room.PlaybackMode,
+			&room.BlockExplicit,
+			&room.PreviewClips,
 			&username,
 		)
 		
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 5
+	maxSearchLimit     = 50
+	// Spotify refuses pages that reach past the first 1000 results.
+	maxSearchResults = 1000
+)
+
+var (
+	ErrInvalidSearch = errors.New("invalid search")
+
+	yearPattern   = regexp.MustCompile(`^\d{4}(-\d{4})?$`)
+	marketPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// SearchQuery is a track search. Text is free text; Artist, Album and Year
+// narrow it down and are combined with it by the provider. An empty Market
+// uses the provider's default.
+type SearchQuery struct {
+	Text   string `json:"search" binding:"max=200"`
+	Artist string `json:"artist" binding:"max=100"`
+	Album  string `json:"album" binding:"max=100"`
+	Year   string `json:"year"`
+	Market string `json:"market"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+
+// Normalize trims the query, fills in the default page size and checks it
+// is something a provider can answer.
+func (q *SearchQuery) Normalize() error {
+	q.Text = strings.Join(strings.Fields(q.Text), " ")
+	q.Artist = strings.Join(strings.Fields(q.Artist), " ")
+	q.Album = strings.Join(strings.Fields(q.Album), " ")
+	q.Year = strings.TrimSpace(q.Year)
+	q.Market = strings.ToUpper(strings.TrimSpace(q.Market))
+
+	if q.Limit == 0 {
+		q.Limit = defaultSearchLimit
+	}
+
+	switch {
+	case q.Text == "" && q.Artist == "" && q.Album == "" && q.Year == "":
+		return fmt.Errorf("%w: search is empty", ErrInvalidSearch)
+	case q.Year != "" && !yearPattern.MatchString(q.Year):
+		return fmt.Errorf("%w: year must look like 1999 or 1990-1999", ErrInvalidSearch)
+	case q.Market != "" && !marketPattern.MatchString(q.Market):
+		return fmt.Errorf("%w: market must be a two letter country code", ErrInvalidSearch)
+	case q.Limit < 1 || q.Limit > maxSearchLimit:
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSearch, maxSearchLimit)
+	case q.Offset < 0 || q.Offset+q.Limit > maxSearchResults:
+		return fmt.Errorf("%w: offset is out of range", ErrInvalidSearch)
+	}
+	return nil
+}
+
+// SpotifyQuery renders the query with Spotify's field filter syntax.
+func (q SearchQuery) SpotifyQuery() string {
+	parts := make([]string, 0, 4)
+	if q.Text != "" {
+		parts = append(parts, q.Text)
+	}
+	if q.Artist != "" {
+		parts = append(parts, "artist:"+quoteFilter(q.Artist))
+	}
+	if q.Album != "" {
+		parts = append(parts, "album:"+quoteFilter(q.Album))
+	}
+	if q.Year != "" {
+		parts = append(parts, "year:"+q.Year)
+	}
+	return strings.Join(parts, " ")
+}
+
+// marketOr returns the query's market, or defaultMarket when it has none.
+func (q SearchQuery) marketOr(defaultMarket string) string {
+	if q.Market == "" {
+		return strings.ToUpper(defaultMarket)
+	}
+	return q.Market
+}
+
+// cacheKey quotes every field, so text containing the separator cannot pass
+// for a different query.
+func (q SearchQuery) cacheKey(provider, defaultMarket string) string {
+	fields := []string{
+		provider,
+		q.marketOr(defaultMarket),
+		strings.ToLower(q.Text),
+		strings.ToLower(q.Artist),
+		strings.ToLower(q.Album),
+		q.Year,
+		strconv.Itoa(q.Offset),
+		strconv.Itoa(q.Limit),
+	}
+	for i, field := range fields {
+		fields[i] = strconv.Quote(field)
+	}
+	return strings.Join(fields, "|")
+}
+
+func quoteFilter(value string) string {
+	value = strings.ReplaceAll(value, `"`, "")
+	if strings.Contains(value, " ") {
+		return `"` + value + `"`
+	}
+	return value
+}
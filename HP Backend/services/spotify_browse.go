@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+
+	"houseparty.com/models"
+	"houseparty.com/spotify"
+)
+
+func (p *SpotifyProvider) ArtistTopTracks(ctx context.Context, artistId string, userId int64) ([]models.Song, error) {
+	var songs []models.Song
+	err := p.withToken(ctx, userId, func(accessToken string) error {
+		tracks, err := p.Client.GetArtistTopTracks(ctx, accessToken, artistId, p.Market)
+		if err != nil {
+			return err
+		}
+		songs = spotify.ToSongs(tracks)
+		return nil
+	})
+	return songs, err
+}
+
+func (p *SpotifyProvider) AlbumTracks(ctx context.Context, albumId string, offset, limit int, userId int64) (*models.SongPage, error) {
+	var page *models.SongPage
+	err := p.withToken(ctx, userId, func(accessToken string) error {
+		album, err := p.Client.GetAlbum(ctx, accessToken, albumId, p.Market)
+		if err != nil {
+			return err
+		}
+
+		tracks := &album.Tracks
+		if offset != tracks.Offset || limit != tracks.Limit {
+			tracks, err = p.Client.GetAlbumTracks(ctx, accessToken, albumId, p.Market, offset, limit)
+			if err != nil {
+				return err
+			}
+		}
+
+		page = &models.SongPage{
+			Songs:  spotify.ToSongs(spotify.WithAlbum(tracks.Items, album.Album)),
+			Offset: tracks.Offset,
+			Limit:  tracks.Limit,
+			Total:  tracks.Total,
+		}
+		return nil
+	})
+	return page, err
+}
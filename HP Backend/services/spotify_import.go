@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"houseparty.com/models"
+	"houseparty.com/spotify"
+)
+
+func (p *SpotifyProvider) ImportTracks(ctx context.Context, link string, userId int64, onPage func(page ImportPage) error) error {
+	parsed, err := spotify.ParseLink(link)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedLink, err)
+	}
+
+	switch parsed.Kind {
+	case spotify.LinkPlaylist:
+		return p.importPlaylist(ctx, parsed.ID, userId, onPage)
+	case spotify.LinkAlbum:
+		return p.importAlbum(ctx, parsed.ID, userId, onPage)
+	default:
+		song, err := p.GetSongById(ctx, parsed.ID, userId)
+		if err != nil {
+			return err
+		}
+		return onPage(ImportPage{Songs: []models.Song{*song}, Total: 1})
+	}
+}
+
+func (p *SpotifyProvider) importPlaylist(ctx context.Context, id string, userId int64, onPage func(page ImportPage) error) error {
+	for offset := 0; ; offset += spotify.PlaylistPageSize {
+		var page *spotify.Paging[spotify.PlaylistItem]
+		err := p.withToken(ctx, userId, func(accessToken string) error {
+			var err error
+			page, err = p.Client.GetPlaylistTracks(ctx, accessToken, id, p.Market, offset, spotify.PlaylistPageSize)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		tracks := make([]spotify.Track, 0, len(page.Items))
+		unavailable := 0
+		for _, item := range page.Items {
+			if item.IsLocal || item.Track == nil {
+				unavailable++
+				continue
+			}
+			tracks = append(tracks, *item.Track)
+		}
+
+		if err := onPage(playablePage(tracks, unavailable, page.Total)); err != nil {
+			return err
+		}
+		if page.Next == "" || len(page.Items) == 0 {
+			return nil
+		}
+	}
+}
+
+func (p *SpotifyProvider) importAlbum(ctx context.Context, id string, userId int64, onPage func(page ImportPage) error) error {
+	var album *spotify.FullAlbum
+	err := p.withToken(ctx, userId, func(accessToken string) error {
+		var err error
+		album, err = p.Client.GetAlbum(ctx, accessToken, id, p.Market)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	page := &album.Tracks
+	for {
+		tracks := spotify.WithAlbum(page.Items, album.Album)
+		if err := onPage(playablePage(tracks, 0, page.Total)); err != nil {
+			return err
+		}
+		if page.Next == "" || len(page.Items) == 0 {
+			return nil
+		}
+
+		offset := page.Offset + len(page.Items)
+		err := p.withToken(ctx, userId, func(accessToken string) error {
+			var err error
+			page, err = p.Client.GetAlbumTracks(ctx, accessToken, id, p.Market, offset, spotify.AlbumPageSize)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// playablePage converts tracks and counts the ones ToSongs drops, because
+// they are missing or unplayable in the market, as unavailable.
+func playablePage(tracks []spotify.Track, unavailable, total int) ImportPage {
+	songs := spotify.ToSongs(tracks)
+	return ImportPage{
+		Songs:       songs,
+		Unavailable: unavailable + len(tracks) - len(songs),
+		Total:       total,
+	}
+}
+
+func (p *SpotifyProvider) ResolveLink(ctx context.Context, raw string) (*ResolvedLink, error) {
+	var link spotify.Link
+	var err error
+	if spotify.IsShortLink(raw) {
+		link, err = p.Client.ResolveShortLink(ctx, raw)
+	} else {
+		link, err = spotify.ParseLink(raw)
+	}
+
+	if errors.Is(err, spotify.ErrInvalidLink) {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedLink, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ResolvedLink{Kind: link.Kind, ID: link.ID, Link: link.URI()}, nil
+}
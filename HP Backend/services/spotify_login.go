@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"houseparty.com/config"
+	"houseparty.com/models"
+	"houseparty.com/spotify"
+	"houseparty.com/validation"
+)
+
+const spotifyUsernameAttempts = 20
+
+var (
+	ErrSpotifyNoEmail       = errors.New("spotify did not share an email address for this account")
+	ErrSpotifyNotLinked     = errors.New("could not log in with this Spotify account, verify your email or log in with your password and connect Spotify from your profile")
+	ErrSpotifyAccountLinked = errors.New("this Spotify account is linked to a different user")
+)
+
+// spotifyAPI reads the profile behind a freshly issued token. It is set up
+// with the providers.
+var spotifyAPI *spotify.Client
+
+type SpotifyLogin struct {
+	User    *models.User
+	Tokens  *AuthTokens
+	Created bool
+}
+
+// LoginWithSpotify finishes "Log in with Spotify". The Spotify account picks
+// the user: the one already linked to it, else the account with the same
+// verified email, else a new account without a password.
+// The Spotify token is stored for that user and a session is started.
+func LoginWithSpotify(ctx context.Context, code, state string) (*SpotifyLogin, error) {
+	codeVerifier, err := config.ConsumeOAuthState(state, config.LoginStateUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := config.ExchangeSpotifyCode(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := spotifyAPI.GetCurrentUser(ctx, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, created, err := spotifyUser(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	token.UserID = user.Id
+	if err := token.SaveToken(); err != nil {
+		return nil, err
+	}
+	if err := user.ActivateSpotify(); err != nil {
+		return nil, err
+	}
+	user.SpotifyConnected = true
+
+	tokens, err := StartSession(user)
+	if err != nil {
+		return nil, err
+	}
+	return &SpotifyLogin{User: user, Tokens: tokens, Created: created}, nil
+}
+
+// ConnectSpotify links a signed in user to the Spotify account they just
+// authorized and stores its token, so they can also log in with Spotify.
+func ConnectSpotify(ctx context.Context, user *models.User, code, codeVerifier string) (*config.SpotifyTokenObject, error) {
+	token, err := config.ExchangeSpotifyCode(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := spotifyAPI.GetCurrentUser(ctx, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.SpotifyID != profile.ID {
+		var linked models.User
+		err := linked.GetUserBySpotifyId(profile.ID)
+		if err == nil {
+			return nil, ErrSpotifyAccountLinked
+		} else if !errors.Is(err, models.ErrUserNotFound) {
+			return nil, err
+		}
+
+		if err := user.LinkSpotify(profile.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	token.UserID = user.Id
+	if err := token.SaveToken(); err != nil {
+		return nil, err
+	}
+	if err := user.ActivateSpotify(); err != nil {
+		return nil, err
+	}
+	user.SpotifyConnected = true
+	return token, nil
+}
+
+// DisconnectSpotify unlinks the user's Spotify account. Accounts without a
+// password have to set one first, or they could not sign in any more.
+func DisconnectSpotify(userId int64) error {
+	var user models.User
+	if err := user.GetUserById(userId); err != nil {
+		return err
+	}
+	hashPassword, err := user.PasswordHash()
+	if err != nil {
+		return err
+	}
+	if hashPassword == "" {
+		return ErrNoPassword
+	}
+	return config.Tokens.Disconnect(userId)
+}
+
+// spotifyUser finds or creates the user for a Spotify profile. An account
+// with the same email is only linked once its owner has verified the address
+// with us; otherwise anyone could set that address on their Spotify account
+// and take over an account that was never confirmed.
+func spotifyUser(ctx context.Context, profile *spotify.User) (*models.User, bool, error) {
+	var user models.User
+	err := user.GetUserBySpotifyId(profile.ID)
+	if err == nil {
+		return &user, false, nil
+	} else if !errors.Is(err, models.ErrUserNotFound) {
+		return nil, false, err
+	}
+
+	if profile.Email == "" {
+		return nil, false, ErrSpotifyNoEmail
+	}
+
+	err = user.GetUserByEmail(profile.Email)
+	if err == nil {
+		if !user.EmailVerified || user.SpotifyID != "" {
+			return nil, false, ErrSpotifyNotLinked
+		}
+		if err := user.LinkSpotify(profile.ID); err != nil {
+			return nil, false, err
+		}
+		return &user, false, nil
+	} else if !errors.Is(err, models.ErrUserNotFound) {
+		return nil, false, err
+	}
+
+	username, err := availableUsername(spotifyUsername(profile))
+	if err != nil {
+		return nil, false, err
+	}
+
+	user = models.User{Username: username, Email: profile.Email}
+	if err := user.SaveFromSpotify(profile.ID); err != nil {
+		return nil, false, err
+	}
+	sendVerificationInBackground(ctx, user)
+	return &user, true, nil
+}
+
+// spotifyUsername turns a display name into something that passes the sign
+// up rules, falling back to the Spotify user id.
+func spotifyUsername(profile *spotify.User) string {
+	keep := func(r rune) rune {
+		switch {
+		case r == ' ':
+			return '_'
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return -1
+	}
+
+	for _, candidate := range []string{profile.DisplayName, profile.ID} {
+		name := strings.Map(keep, candidate)
+		// Leave room for the suffix availableUsername may add.
+		if len(name) > 28 {
+			name = name[:28]
+		}
+		if validation.Var(name, "min=3,max=32,username,notreserved") == nil {
+			return name
+		}
+	}
+	return "listener"
+}
+
+func availableUsername(base string) (string, error) {
+	for attempt := 1; attempt <= spotifyUsernameAttempts; attempt++ {
+		username := base
+		if attempt > 1 {
+			username = fmt.Sprintf("%s-%d", base, attempt)
+		}
+
+		err := checkInUse(username, "")
+		if err == nil {
+			return username, nil
+		} else if !errors.Is(err, ErrUsernameInUse) {
+			return "", err
+		}
+	}
+	return "", ErrUsernameInUse
+}
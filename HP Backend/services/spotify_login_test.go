@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"houseparty.com/config"
+	"houseparty.com/mail"
+	"houseparty.com/models"
+	"houseparty.com/spotify"
+	"houseparty.com/storage"
+)
+
+// recordingMailer hands every message to the test instead of sending it.
+type recordingMailer struct {
+	sent chan mail.Message
+}
+
+func useRecordingMailer(t *testing.T) *recordingMailer {
+	t.Helper()
+	recorder := &recordingMailer{sent: make(chan mail.Message, 10)}
+	previous := mailer
+	InitMailer(recorder)
+	t.Cleanup(func() { InitMailer(previous) })
+	return recorder
+}
+
+func (m *recordingMailer) Send(ctx context.Context, message mail.Message) error {
+	m.sent <- message
+	return nil
+}
+
+func (m *recordingMailer) next(t *testing.T) mail.Message {
+	t.Helper()
+	select {
+	case message := <-m.sent:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatal("no email was sent")
+		return mail.Message{}
+	}
+}
+
+// fakeSpotify answers token exchanges and /me with profile.
+func fakeSpotify(t *testing.T, profile spotify.User) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/api/token":
+			w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":3600,"refresh_token":"refresh","scope":"streaming"}`))
+		case strings.HasSuffix(r.URL.Path, "/me"):
+			json.NewEncoder(w).Encode(profile)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("SPOTIFY_ACCOUNTS_URL", server.URL)
+	t.Setenv("SPOTIFY_CLIENT_ID", "client")
+	t.Setenv("SPOTIFY_CLIENT_SECRET", "secret")
+	previous := spotifyAPI
+	spotifyAPI = spotify.NewClient(server.URL, server.Client())
+	t.Cleanup(func() { spotifyAPI = previous })
+}
+
+func loginWithSpotify(t *testing.T) (*SpotifyLogin, error) {
+	t.Helper()
+	state, err := config.NewOAuthState(config.LoginStateUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return LoginWithSpotify(context.Background(), "code", state.State)
+}
+
+func verifyTestEmail(t *testing.T, user *models.User) {
+	t.Helper()
+	if err := user.VerifyEmail(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertSpotifyToken(t *testing.T, userId int64) {
+	t.Helper()
+	token, err := config.GetTokenFromDB(userId)
+	if err != nil {
+		t.Fatalf("no spotify token stored for user %d: %v", userId, err)
+	}
+	if token.AccessToken != "access" {
+		t.Errorf("stored access token = %q, want access", token.AccessToken)
+	}
+}
+
+func TestLoginWithSpotifyCreatesUser(t *testing.T) {
+	openTestDB(t)
+	recorder := useRecordingMailer(t)
+	fakeSpotify(t, spotify.User{ID: "spotify-erin", DisplayName: "Erin Example", Email: "erin@example.com"})
+
+	login, err := loginWithSpotify(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !login.Created || login.User.Username != "Erin_Example" || login.Tokens == nil {
+		t.Errorf("login = %+v, want a new Erin_Example with a session", login)
+	}
+	assertSpotifyToken(t, login.User.Id)
+	if message := recorder.next(t); message.To != "erin@example.com" {
+		t.Errorf("verification sent to %q", message.To)
+	}
+}
+
+func TestLoginWithSpotifyFindsLinkedUser(t *testing.T) {
+	openTestDB(t)
+	useRecordingMailer(t)
+	fakeSpotify(t, spotify.User{ID: "spotify-frank", DisplayName: "Frank", Email: "new-address@example.com"})
+	user := createTestUser(t, "frank", "correct-horse1")
+	if err := user.LinkSpotify("spotify-frank"); err != nil {
+		t.Fatal(err)
+	}
+
+	login, err := loginWithSpotify(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.Created || login.User.Id != user.Id {
+		t.Errorf("logged in as user %d (created %v), want the linked user %d", login.User.Id, login.Created, user.Id)
+	}
+	assertSpotifyToken(t, user.Id)
+}
+
+func TestLoginWithSpotifyMergesVerifiedEmail(t *testing.T) {
+	openTestDB(t)
+	useRecordingMailer(t)
+	fakeSpotify(t, spotify.User{ID: "spotify-grace", DisplayName: "Grace", Email: "grace@example.com"})
+	user := createTestUser(t, "grace", "correct-horse1")
+	verifyTestEmail(t, user)
+
+	login, err := loginWithSpotify(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.Created || login.User.Id != user.Id || login.Tokens == nil {
+		t.Fatalf("logged in as user %d (created %v), want the verified user %d", login.User.Id, login.Created, user.Id)
+	}
+	assertSpotifyToken(t, user.Id)
+
+	var linked models.User
+	if err := linked.GetUserBySpotifyId("spotify-grace"); err != nil || linked.Id != user.Id {
+		t.Errorf("spotify account linked to user %d (%v), want %d", linked.Id, err, user.Id)
+	}
+}
+
+func TestLoginWithSpotifyRefusesUnverifiedEmail(t *testing.T) {
+	openTestDB(t)
+	useRecordingMailer(t)
+	fakeSpotify(t, spotify.User{ID: "spotify-mallory", DisplayName: "Mallory", Email: "heidi@example.com"})
+	user := createTestUser(t, "heidi", "correct-horse1")
+
+	if _, err := loginWithSpotify(t); !errors.Is(err, ErrSpotifyNotLinked) {
+		t.Fatalf("error = %v, want %v", err, ErrSpotifyNotLinked)
+	}
+	if _, err := config.GetTokenFromDB(user.Id); !errors.Is(err, config.ErrTokenNotFound) {
+		t.Errorf("a token was stored for the unverified account: %v", err)
+	}
+	var linked models.User
+	if err := linked.GetUserBySpotifyId("spotify-mallory"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("spotify account was linked to user %d", linked.Id)
+	}
+}
+
+func TestLoginWithSpotifyPicksFreeUsername(t *testing.T) {
+	openTestDB(t)
+	recorder := useRecordingMailer(t)
+	fakeSpotify(t, spotify.User{ID: "spotify-ivan", DisplayName: "ivan!", Email: "ivan.spotify@example.com"})
+	createTestUser(t, "ivan", "correct-horse1")
+	createTestUser(t, "ivan-2", "correct-horse1")
+
+	login, err := loginWithSpotify(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.User.Username != "ivan-3" {
+		t.Errorf("username = %q, want ivan-3", login.User.Username)
+	}
+	recorder.next(t)
+}
+
+func TestSpotifyUsername(t *testing.T) {
+	tests := []struct {
+		profile spotify.User
+		want    string
+	}{
+		{profile: spotify.User{ID: "id", DisplayName: "Judy Smith"}, want: "Judy_Smith"},
+		{profile: spotify.User{ID: "judy123", DisplayName: "!!"}, want: "judy123"},
+		{profile: spotify.User{ID: "admin", DisplayName: "Admin"}, want: "listener"},
+		{profile: spotify.User{ID: "id", DisplayName: strings.Repeat("a", 40)}, want: strings.Repeat("a", 28)},
+	}
+
+	for _, test := range tests {
+		if got := spotifyUsername(&test.profile); got != test.want {
+			t.Errorf("spotifyUsername(%q, %q) = %q, want %q", test.profile.DisplayName, test.profile.ID, got, test.want)
+		}
+	}
+}
+
+func TestConnectSpotify(t *testing.T) {
+	openTestDB(t)
+	fakeSpotify(t, spotify.User{ID: "spotify-kim", Email: "someone-else@example.com"})
+	user := createTestUser(t, "kim", "correct-horse1")
+
+	if _, err := ConnectSpotify(context.Background(), user, "code", "verifier"); err != nil {
+		t.Fatal(err)
+	}
+	if user.SpotifyID != "spotify-kim" || !user.SpotifyConnected {
+		t.Errorf("user = %+v, want spotify-kim connected", user)
+	}
+	assertSpotifyToken(t, user.Id)
+
+	other := createTestUser(t, "leo", "correct-horse1")
+	if _, err := ConnectSpotify(context.Background(), other, "code", "verifier"); !errors.Is(err, ErrSpotifyAccountLinked) {
+		t.Errorf("connecting a linked spotify account = %v, want %v", err, ErrSpotifyAccountLinked)
+	}
+}
+
+func TestDisconnectSpotify(t *testing.T) {
+	openTestDB(t)
+	fakeSpotify(t, spotify.User{ID: "spotify-mia"})
+	user := createTestUser(t, "mia", "correct-horse1")
+	if _, err := ConnectSpotify(context.Background(), user, "code", "verifier"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DisconnectSpotify(user.Id); err != nil {
+		t.Fatal(err)
+	}
+	var reloaded models.User
+	if err := reloaded.GetUserById(user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.SpotifyID != "" || reloaded.SpotifyConnected {
+		t.Errorf("user still linked after disconnecting: %+v", reloaded)
+	}
+	if _, err := config.GetTokenFromDB(user.Id); !errors.Is(err, config.ErrTokenNotFound) {
+		t.Errorf("token kept after disconnecting: %v", err)
+	}
+
+	// Without a password the Spotify link is the only way in.
+	if _, err := storage.DB.Exec(`UPDATE users SET password = '' WHERE id = ?`, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := DisconnectSpotify(user.Id); !errors.Is(err, ErrNoPassword) {
+		t.Errorf("disconnecting without a password = %v, want %v", err, ErrNoPassword)
+	}
+}
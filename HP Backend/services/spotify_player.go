@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+
+	"houseparty.com/config"
+	"houseparty.com/models"
+	"houseparty.com/spotify"
+)
+
+func (p *SpotifyProvider) ListDevices(ctx context.Context, userId int64) ([]models.Device, error) {
+	var devices []models.Device
+	err := p.withToken(ctx, userId, func(accessToken string) error {
+		spotifyDevices, err := p.Client.GetDevices(ctx, accessToken)
+		if err != nil {
+			return err
+		}
+		for _, device := range spotifyDevices {
+			if device.IsRestricted || device.ID == "" {
+				continue
+			}
+			devices = append(devices, models.Device{
+				ID:            device.ID,
+				Name:          device.Name,
+				Type:          device.Type,
+				IsActive:      device.IsActive,
+				VolumePercent: device.VolumePercent,
+			})
+		}
+		return nil
+	})
+	return devices, err
+}
+
+func (p *SpotifyProvider) StartPlayback(ctx context.Context, userId int64, deviceId string, song *models.Song, positionMs int) error {
+	return p.withToken(ctx, userId, func(accessToken string) error {
+		return p.Client.Play(ctx, accessToken, deviceId, []string{song.URI}, positionMs)
+	})
+}
+
+func (p *SpotifyProvider) PausePlayback(ctx context.Context, userId int64, deviceId string) error {
+	return p.withToken(ctx, userId, func(accessToken string) error {
+		return p.Client.Pause(ctx, accessToken, deviceId)
+	})
+}
+
+func (p *SpotifyProvider) PlaybackState(ctx context.Context, userId int64) (*models.PlaybackState, error) {
+	var state *models.PlaybackState
+	err := p.withToken(ctx, userId, func(accessToken string) error {
+		spotifyState, err := p.Client.GetPlaybackState(ctx, accessToken)
+		if err != nil || spotifyState == nil {
+			return err
+		}
+		state = toPlaybackState(spotifyState)
+		return nil
+	})
+	return state, err
+}
+
+func (p *SpotifyProvider) withToken(ctx context.Context, userId int64, call func(accessToken string) error) error {
+	return p.guard(userId, func() error {
+		token, err := config.GetSpotifyTokenObject(ctx, userId)
+		if err != nil {
+			return err
+		}
+		return call(token.AccessToken)
+	})
+}
+
+func toPlaybackState(state *spotify.PlaybackState) *models.PlaybackState {
+	playbackState := &models.PlaybackState{
+		DeviceID:   state.Device.ID,
+		IsPlaying:  state.IsPlaying,
+		ProgressMs: state.ProgressMs,
+	}
+	if state.Item != nil {
+		playbackState.TrackID = state.Item.ID
+		playbackState.TrackURI = state.Item.URI
+		if state.Item.LinkedFrom != nil {
+			playbackState.LinkedFromID = state.Item.LinkedFrom.ID
+			playbackState.LinkedFromURI = state.Item.LinkedFrom.URI
+		}
+	}
+	return playbackState
+}
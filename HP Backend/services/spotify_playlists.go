@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+
+	"houseparty.com/config"
+	"houseparty.com/models"
+)
+
+func (p *SpotifyProvider) CreatePlaylist(ctx context.Context, userId int64, name, description string, public bool, songs []models.Song) (*models.Playlist, error) {
+	scope := config.ScopePlaylistModifyPrivate
+	if public {
+		scope = config.ScopePlaylistModifyPublic
+	}
+
+	uris := make([]string, 0, len(songs))
+	for _, song := range songs {
+		if song.URI != "" {
+			uris = append(uris, song.URI)
+		}
+	}
+
+	var playlist *models.Playlist
+	err := p.guard(userId, func() error {
+		token, err := config.GetSpotifyTokenObject(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if !token.HasScope(scope) {
+			return ErrMissingScope
+		}
+
+		created, err := p.Client.CreatePlaylist(ctx, token.AccessToken, name, description, public)
+		if err != nil {
+			return err
+		}
+		playlist = &models.Playlist{
+			ID:         created.ID,
+			Name:       created.Name,
+			URL:        created.ExternalURLs.Spotify,
+			TrackCount: len(uris),
+		}
+
+		return p.Client.AddPlaylistTracks(ctx, token.AccessToken, created.ID, uris)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
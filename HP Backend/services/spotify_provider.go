@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"houseparty.com/config"
+	"houseparty.com/httpclient"
+	"houseparty.com/models"
+	"houseparty.com/spotify"
+)
+
+const (
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+type SpotifyProvider struct {
+	Client   *spotify.Client
+	Market   string
+	breakers *httpclient.BreakerSet[int64]
+}
+
+func NewSpotifyProvider(client *spotify.Client, market string) *SpotifyProvider {
+	return &SpotifyProvider{
+		Client:   client,
+		Market:   market,
+		breakers: httpclient.NewBreakerSet[int64](breakerThreshold, breakerCooldown),
+	}
+}
+
+func (p *SpotifyProvider) Name() string {
+	return "spotify"
+}
+
+func (p *SpotifyProvider) SearchSongs(ctx context.Context, query SearchQuery, userId int64) (*models.SongPage, error) {
+	if query.Market == "" {
+		query.Market = p.Market
+	}
+
+	var page *models.SongPage
+	err := p.guard(userId, func() error {
+		var err error
+		page, err = SearchSongs(ctx, p.Client, query, userId)
+		return err
+	})
+	return page, err
+}
+
+func (p *SpotifyProvider) GetSongById(ctx context.Context, id string, userId int64) (*models.Song, error) {
+	var song *models.Song
+	err := p.guard(userId, func() error {
+		var err error
+		song, err = GetSongById(ctx, p.Client, id, userId)
+		return err
+	})
+	return song, err
+}
+
+func (p *SpotifyProvider) ExchangeCode(ctx context.Context, code, codeVerifier string, userId int64) error {
+	_, err := config.SetSpotifyToken(ctx, code, codeVerifier, userId)
+	return err
+}
+
+func (p *SpotifyProvider) AccessToken(ctx context.Context, userId int64) (string, error) {
+	var accessToken string
+	err := p.guard(userId, func() error {
+		token, err := config.GetSpotifyTokenObject(ctx, userId)
+		if err != nil {
+			return err
+		}
+		accessToken = token.AccessToken
+		return nil
+	})
+	return accessToken, err
+}
+
+func (p *SpotifyProvider) RefreshToken(ctx context.Context, userId int64) (string, error) {
+	token, err := config.Tokens.Refresh(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// guard runs call behind the circuit breaker of the host whose token is used,
+// so one host being rate limited does not affect other rooms.
+func (p *SpotifyProvider) guard(userId int64, call func() error) error {
+	breaker := p.breakers.Get(userId)
+	if err := breaker.Allow(); err != nil {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+
+	err := call()
+	switch {
+	case err == nil:
+		breaker.Success()
+	case isTransient(err):
+		breaker.Failure()
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	default:
+		breaker.Neutral()
+	}
+	return err
+}
+
+func isTransient(err error) bool {
+	var apiError *spotify.APIError
+	if errors.As(err, &apiError) {
+		return httpclient.IsTransientStatus(apiError.Status)
+	}
+
+	var tokenError *config.TokenRequestError
+	if errors.As(err, &tokenError) {
+		return httpclient.IsTransientStatus(tokenError.Status)
+	}
+
+	var netError net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netError)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"time"
+
+	"houseparty.com/models"
+	"houseparty.com/utils"
+)
+
+const roomTicketTTL = 30 * time.Second
+
+// IssueRoomTicket returns a single-use ticket for opening the room's
+// WebSocket, so the access token never has to go in the URL.
+func IssueRoomTicket(roomId string, userId int64, sessionId string) (string, *models.RoomTicket, error) {
+	var room models.Room
+	if err := room.GetRoomById(roomId); err != nil {
+		return "", nil, err
+	}
+
+	ticket, err := utils.GenerateRandomToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	roomTicket := &models.RoomTicket{
+		UserID:    userId,
+		SessionID: sessionId,
+		RoomID:    room.ID,
+		ExpiresAt: time.Now().Add(roomTicketTTL).Unix(),
+	}
+	if err := roomTicket.Save(ticket); err != nil {
+		return "", nil, err
+	}
+	return ticket, roomTicket, nil
+}
+
+// RedeemRoomTicket spends a ticket for roomId, failing if its session was
+// logged out after it was issued.
+func RedeemRoomTicket(ticket, roomId string) (*models.RoomTicket, error) {
+	roomTicket, err := models.RedeemRoomTicket(ticket, roomId)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := models.GetSessionById(roomTicket.SessionID)
+	if err != nil || session.Revoked() {
+		return nil, ErrSessionRevoked
+	}
+	return roomTicket, nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"houseparty.com/cache"
+	"houseparty.com/logging"
+	"houseparty.com/metrics"
+	"houseparty.com/models"
+	"houseparty.com/storage"
+)
+
+var (
+	searchCache *cache.LRU[string, models.SongPage]
+	trackHits   atomic.Uint64
+	trackMisses atomic.Uint64
+)
+
+type TrackCacheStats struct {
+	Size   int    `json:"size"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+type CacheStats struct {
+	Search cache.Stats     `json:"search"`
+	Tracks TrackCacheStats `json:"tracks"`
+}
+
+// CachingProvider answers searches from an in-memory LRU and track lookups
+// from the SQLite track cache before falling through to the wrapped provider.
+// Tracks are cached per market, since availability and preview clips differ
+// between them; lookups by id use the provider's default market.
+type CachingProvider struct {
+	MusicProvider
+	market string
+}
+
+func NewCachingProvider(provider MusicProvider, market string) *CachingProvider {
+	return &CachingProvider{MusicProvider: provider, market: market}
+}
+
+func (p *CachingProvider) Unwrap() MusicProvider {
+	return p.MusicProvider
+}
+
+func (p *CachingProvider) SearchSongs(ctx context.Context, query SearchQuery, userId int64) (*models.SongPage, error) {
+	key := query.cacheKey(p.Name(), p.market)
+	if page, ok := searchCache.Get(key); ok {
+		metrics.CacheRequests.WithLabelValues("search", "hit").Inc()
+		page.Songs = slices.Clone(page.Songs)
+		return &page, nil
+	}
+	metrics.CacheRequests.WithLabelValues("search", "miss").Inc()
+
+	page, err := p.MusicProvider.SearchSongs(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := *page
+	cached.Songs = slices.Clone(page.Songs)
+	searchCache.Set(key, cached)
+	market := query.marketOr(p.market)
+	for i := range page.Songs {
+		cacheTrack(ctx, p.Name(), market, &page.Songs[i])
+	}
+	return page, nil
+}
+
+func (p *CachingProvider) GetSongById(ctx context.Context, id string, userId int64) (*models.Song, error) {
+	market := strings.ToUpper(p.market)
+	if song, ok := getCachedTrack(p.Name(), market, id); ok {
+		return song, nil
+	}
+
+	song, err := p.MusicProvider.GetSongById(ctx, id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheTrack(ctx, p.Name(), market, song)
+	return song, nil
+}
+
+// ImportTracks passes imports through to the wrapped provider, keeping the
+// imported songs in the track cache for later lookups.
+func (p *CachingProvider) ImportTracks(ctx context.Context, link string, userId int64, onPage func(page ImportPage) error) error {
+	importer, ok := TrackImporterFor(p.MusicProvider)
+	if !ok {
+		return ErrUnsupportedLink
+	}
+
+	return importer.ImportTracks(ctx, link, userId, func(page ImportPage) error {
+		for i := range page.Songs {
+			cacheTrack(ctx, p.Name(), strings.ToUpper(p.market), &page.Songs[i])
+		}
+		return onPage(page)
+	})
+}
+
+func getCachedTrack(provider, market, id string) (*models.Song, bool) {
+	var data string
+	err := storage.DB.QueryRow(storage.GetCachedTrackQuery, provider, market, id).Scan(&data)
+	if err != nil {
+		trackMisses.Add(1)
+		metrics.CacheRequests.WithLabelValues("track", "miss").Inc()
+		return nil, false
+	}
+
+	var song models.Song
+	if err := json.Unmarshal([]byte(data), &song); err != nil {
+		trackMisses.Add(1)
+		metrics.CacheRequests.WithLabelValues("track", "miss").Inc()
+		return nil, false
+	}
+
+	trackHits.Add(1)
+	metrics.CacheRequests.WithLabelValues("track", "hit").Inc()
+	return &song, true
+}
+
+// cacheTrack stores a song in the track cache. A song that could not be
+// cached is only fetched again later, so the lookup that found it goes on.
+func cacheTrack(ctx context.Context, provider, market string, song *models.Song) {
+	if err := saveCachedTrack(provider, market, song); err != nil {
+		logging.FromContext(ctx).Warn("could not cache track", "provider", provider, "market", market, "song_id", song.Id, "error", err)
+	}
+}
+
+func saveCachedTrack(provider, market string, song *models.Song) error {
+	if song == nil || song.Id == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(song)
+	if err != nil {
+		return err
+	}
+	_, err = storage.DB.Exec(storage.SaveCachedTrackQuery, provider, market, song.Id, string(data), time.Now().Unix())
+	return err
+}
+
+func GetCacheStats() (*CacheStats, error) {
+	var size int
+	err := storage.DB.QueryRow(storage.CountCachedTracksQuery).Scan(&size)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return &CacheStats{
+		Search: searchCache.Stats(),
+		Tracks: TrackCacheStats{
+			Size:   size,
+			Hits:   trackHits.Load(),
+			Misses: trackMisses.Load(),
+		},
+	}, nil
+}
+
+func InvalidateSearchCache() {
+	searchCache.Purge()
+}
+
+// InvalidateTrackCache removes one cached track in every market, or every
+// cached track of the provider when trackId is empty.
+func InvalidateTrackCache(provider, trackId string) error {
+	if trackId == "" {
+		_, err := storage.DB.Exec(storage.DeleteProviderCachedTracksQuery, provider)
+		return err
+	}
+
+	_, err := storage.DB.Exec(storage.DeleteCachedTrackQuery, provider, trackId)
+	return err
+}
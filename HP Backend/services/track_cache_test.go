@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"houseparty.com/cache"
+	"houseparty.com/models"
+)
+
+func TestSearchCacheKeysDoNotCollide(t *testing.T) {
+	queries := []SearchQuery{
+		{Text: "a|b", Limit: 5},
+		{Text: "a", Artist: "b", Limit: 5},
+		{Text: `a"|"b`, Limit: 5},
+		{Text: "a", Album: "b", Limit: 5},
+		{Text: "a", Market: "US", Limit: 5},
+		{Text: "a", Year: "1999|0", Limit: 5},
+		{Text: "a", Year: "1999", Limit: 5},
+	}
+
+	seen := make(map[string]SearchQuery)
+	for _, query := range queries {
+		key := query.cacheKey("spotify", "GB")
+		if other, ok := seen[key]; ok {
+			t.Errorf("%+v and %+v share the cache key %s", query, other, key)
+		}
+		seen[key] = query
+	}
+
+	defaultMarket := SearchQuery{Text: "a", Limit: 5}
+	explicitMarket := SearchQuery{Text: "a", Market: "GB", Limit: 5}
+	if defaultMarket.cacheKey("spotify", "gb") != explicitMarket.cacheKey("spotify", "GB") {
+		t.Error("the default market and the same explicit market are cached apart")
+	}
+}
+
+func TestTrackCacheIsPerMarket(t *testing.T) {
+	openTestDB(t)
+	previous := searchCache
+	searchCache = cache.NewLRU[string, models.SongPage](10, time.Minute)
+	t.Cleanup(func() { searchCache = previous })
+
+	fake := NewFakeProvider(defaultFakeCatalogue()...)
+	german := &models.Song{Id: "fake-1", Name: "Mitternachtsfahrt", PreviewURL: "https://p.scdn.co/mp3-preview/de"}
+	if err := saveCachedTrack(fake.Name(), "DE", german); err != nil {
+		t.Fatal(err)
+	}
+
+	song, err := NewCachingProvider(fake, "de").GetSongById(context.Background(), "fake-1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if song.Name != german.Name {
+		t.Errorf("song in DE = %q, want the cached %q", song.Name, german.Name)
+	}
+
+	song, err = NewCachingProvider(fake, "GB").GetSongById(context.Background(), "fake-1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if song.Name != "Midnight Drive" {
+		t.Errorf("song in GB = %q, want the provider's own, not the DE copy", song.Name)
+	}
+
+	// Songs found by a search are cached under the market searched in.
+	if _, err := NewCachingProvider(fake, "GB").SearchSongs(context.Background(), SearchQuery{Text: "green", Market: "US", Limit: 5}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := getCachedTrack(fake.Name(), "US", "fake-5"); !ok {
+		t.Error("searched song was not cached for US")
+	}
+	if _, ok := getCachedTrack(fake.Name(), "GB", "fake-5"); ok {
+		t.Error("song searched in US was cached for GB")
+	}
+}
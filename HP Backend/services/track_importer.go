@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"houseparty.com/models"
+)
+
+var ErrUnsupportedLink = errors.New("link is not supported by this music provider")
+
+// ImportPage is one page of an imported collection. Unavailable counts items
+// the provider returned but that cannot be played, such as local files or
+// tracks not released in the configured market.
+type ImportPage struct {
+	Songs       []models.Song
+	Unavailable int
+	Total       int
+}
+
+// TrackImporter is implemented by providers that can expand a playlist, album
+// or track link into songs. onPage is called once per fetched page; returning
+// an error from it stops the import and is passed back to the caller.
+type TrackImporter interface {
+	ImportTracks(ctx context.Context, link string, userId int64, onPage func(page ImportPage) error) error
+}
+
+func TrackImporterFor(provider MusicProvider) (TrackImporter, bool) {
+	return capability[TrackImporter](provider)
+}
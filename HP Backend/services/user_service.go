@@ -1,62 +1,107 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"houseparty.com/models"
 	"houseparty.com/storage"
 	"houseparty.com/utils"
 )
 
-func CreateNewUser(user *models.User) (string, error) {
+var (
+	ErrUsernameInUse = errors.New("Username already in use.")
+	ErrEmailInUse    = errors.New("Email already in use.")
+)
 
-	isInUse, errorString := checkInUse(user.Username, user.Email)
+func CreateNewUser(ctx context.Context, user *models.User) (*AuthTokens, error) {
 
-	if isInUse {
-		err := errors.New(errorString)
-		return "", err
+	err := checkInUse(user.Username, user.Email)
+	if err != nil {
+		return nil, err
 	}
 
-	err := user.Save()
+	err = user.Save()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	sendVerificationInBackground(ctx, *user)
 
-	return utils.GenerateToken(user.Email, user.Username, user.Id)
+	return StartSession(user)
 }
 
-func ValidateCredentials(user *models.User) (string, error){
+// ValidateCredentials signs the user in unless the account or the client
+// address has been locked by earlier failures. Every attempt is counted as a
+// failure before the password is checked and given back if it succeeds.
+// Unknown logins and wrong passwords fail the same way.
+func ValidateCredentials(ctx context.Context, user *models.User, clientAddress string) (*AuthTokens, error){
+	now := time.Now()
 	hashPassword, err :=  user.RetrieveHashPassword()
-	if err != nil{
-		return "", err
+	found := err == nil
+	if err != nil && !errors.Is(err, models.ErrUserNotFound) {
+		return nil, err
+	}
+
+	accountKey := unknownLoginKey(user)
+	if found {
+		accountKey = models.AccountLoginKey(user.Id)
+	}
+
+	// Accounts created through Spotify have no password. Like unknown logins
+	// they are checked against a dummy hash so timing does not give them away.
+	hasPassword := found && hashPassword != ""
+	if !hasPassword {
+		hashPassword = dummyPasswordHash()
+	}
+
+	address, err := reserveLoginAttempt(ctx, addressLoginKey(clientAddress), addressLoginPolicy, now)
+	if err != nil {
+		return nil, err
 	}
+	if _, err := reserveLoginAttempt(ctx, accountKey, accountLoginPolicy, now); err != nil {
+		// No password was tried, so the address is not charged for it.
+		if refundErr := address.Refund(); refundErr != nil {
+			return nil, refundErr
+		}
+		return nil, err
+	}
+
 	isValidPassword := utils.CheckPasswordHash(user.Password, hashPassword)
 
-	if !isValidPassword{
-		return "", errors.New("password that is provided is incorrect")
+	if !isValidPassword || !hasPassword {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := address.Refund(); err != nil {
+		return nil, err
+	}
+	err = models.ClearLoginAttempts(accountKey)
+	if err != nil{
+		return nil, err
 	}
 
 	err = user.GetUserById(user.Id)
 	if err != nil{
-		return "", err
+		return nil, err
 	}
 
-	return utils.GenerateToken(user.Email, user.Username, user.Id)
+	return StartSession(user)
 }
 
-func checkInUse(username, email string) (bool, string) { 
+func checkInUse(username, email string) error { 
 	var conflictField string
 	err := storage.DB.QueryRow(storage.CheckInUseQuery, username, email, username, email).Scan(&conflictField)
 
 	if err != nil && err != sql.ErrNoRows {
-		return true, "Database Error"
+		return err
 	} else if conflictField == "email"{
-		return true, "Email already in use."
+		return ErrEmailInUse
 	}else if conflictField == "username"{
-		return true, "Username already in use."
+		return ErrUsernameInUse
 	}
 
-	return false, ""
+	return nil
 }
 
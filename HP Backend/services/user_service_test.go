@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"houseparty.com/models"
+	"houseparty.com/storage"
+	"houseparty.com/validation"
+)
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("SECRET_JWT_KEY", "test-key")
+	if err := validation.Init(); err != nil {
+		t.Fatal(err)
+	}
+	storage.OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	t.Cleanup(func() { storage.DB.Close() })
+}
+
+// createTestUser stores a user directly, with a cheap hash so tests do not
+// spend seconds in bcrypt.
+func createTestUser(t *testing.T, username, password string) *models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := storage.DB.Exec(storage.SaveUserQuery, username+"@example.com", string(hash), username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := result.LastInsertId()
+	return &models.User{Id: id, Username: username, Email: username + "@example.com"}
+}
+
+func TestValidateCredentialsLocksOutParallelGuesses(t *testing.T) {
+	openTestDB(t)
+	createTestUser(t, "alice", "correct-horse1")
+
+	const guesses = 30
+	var wg sync.WaitGroup
+	errs := make([]error, guesses)
+	for i := range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Every guess comes from its own address, so only the account
+			// lock can stop them.
+			login := &models.User{Username: "alice", Password: fmt.Sprint("guess-", i)}
+			_, errs[i] = ValidateCredentials(context.Background(), login, fmt.Sprint("203.0.113.", i))
+		}()
+	}
+	wg.Wait()
+
+	checked, throttled := 0, 0
+	for _, err := range errs {
+		var throttledError *LoginThrottledError
+		switch {
+		case errors.Is(err, ErrInvalidCredentials):
+			checked++
+		case errors.As(err, &throttledError):
+			throttled++
+		default:
+			t.Fatalf("unexpected result: %v", err)
+		}
+	}
+	if checked != accountLoginPolicy.FreeAttempts {
+		t.Errorf("%d guesses reached the password check, want %d", checked, accountLoginPolicy.FreeAttempts)
+	}
+	if throttled != guesses-checked {
+		t.Errorf("%d guesses were throttled, want %d", throttled, guesses-checked)
+	}
+
+	_, err := ValidateCredentials(context.Background(), &models.User{Username: "alice", Password: "correct-horse1"}, "198.51.100.1")
+	var throttledError *LoginThrottledError
+	if !errors.As(err, &throttledError) {
+		t.Errorf("login during the lock = %v, want a LoginThrottledError", err)
+	}
+}
+
+func TestValidateCredentialsDoesNotChargeSuccessfulLogins(t *testing.T) {
+	openTestDB(t)
+	createTestUser(t, "bob", "correct-horse1")
+
+	// More successful logins than the address allows failures.
+	for i := range addressLoginPolicy.FreeAttempts + 5 {
+		login := &models.User{Username: "bob", Password: "correct-horse1"}
+		if _, err := ValidateCredentials(context.Background(), login, "192.0.2.1"); err != nil {
+			t.Fatalf("login %d: %v", i+1, err)
+		}
+	}
+
+	attempts, reserved, err := models.ReserveLoginAttempt(addressLoginKey("192.0.2.1"), time.Now(), loginFailureWindow, addressLoginPolicy.delay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reserved || attempts.Failures != 1 {
+		t.Errorf("address has %d failures after only successful logins, want the new attempt alone", attempts.Failures-1)
+	}
+}
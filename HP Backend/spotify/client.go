@@ -0,0 +1,128 @@
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"houseparty.com/metrics"
+)
+
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+type APIError struct {
+	Operation string
+	Status    int
+	Message   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("spotify %s request failed with status %d: %s", e.Operation, e.Status, e.Message)
+}
+
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: httpClient,
+	}
+}
+
+func (c *Client) SearchTracks(ctx context.Context, accessToken, search, market string, offset, limit int) (*SearchResponse, error) {
+	query := pageQuery(market, offset, limit)
+	query.Set("q", strings.TrimSpace(search))
+	query.Set("type", "track")
+
+	var response SearchResponse
+	err := c.get(ctx, "SearchSongs", accessToken, "/search", query, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *Client) GetTrack(ctx context.Context, accessToken, id string) (*Track, error) {
+	var track Track
+	err := c.get(ctx, "GetSongById", accessToken, "/tracks/"+url.PathEscape(id), nil, &track)
+	if err != nil {
+		return nil, err
+	}
+	return &track, nil
+}
+
+func (c *Client) get(ctx context.Context, operation, accessToken, path string, query url.Values, out any) error {
+	_, err := c.do(ctx, http.MethodGet, operation, accessToken, path, query, nil, out)
+	return err
+}
+
+// do sends a request and decodes a JSON response into out when out is not
+// nil. It returns the status code so callers can tell a 204 from a body.
+func (c *Client) do(ctx context.Context, method, operation, accessToken, path string, query url.Values, body any, out any) (int, error) {
+	endpoint := c.BaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := c.HTTPClient.Do(req)
+	metrics.ObserveSpotifyCall(operation, start, resp)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, newAPIError(operation, resp)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return resp.StatusCode, nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("spotify %s returned an invalid body: %w", operation, err)
+	}
+	return resp.StatusCode, nil
+}
+
+func newAPIError(operation string, resp *http.Response) *APIError {
+	apiError := &APIError{Operation: operation, Status: resp.StatusCode}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var parsed errorResponse
+	if json.Unmarshal(body, &parsed) == nil && parsed.Error.Message != "" {
+		apiError.Message = parsed.Error.Message
+	} else {
+		apiError.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiError
+}
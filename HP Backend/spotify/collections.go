@@ -0,0 +1,72 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+const (
+	PlaylistPageSize = 100
+	AlbumPageSize    = 50
+)
+
+func (c *Client) GetPlaylistTracks(ctx context.Context, accessToken, id, market string, offset, limit int) (*Paging[PlaylistItem], error) {
+	query := pageQuery(market, offset, limit)
+	query.Set("additional_types", "track")
+
+	var page Paging[PlaylistItem]
+	err := c.get(ctx, "GetPlaylistTracks", accessToken, "/playlists/"+url.PathEscape(id)+"/tracks", query, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (c *Client) GetAlbum(ctx context.Context, accessToken, id, market string) (*FullAlbum, error) {
+	query := url.Values{}
+	if market != "" {
+		query.Set("market", market)
+	}
+
+	var album FullAlbum
+	err := c.get(ctx, "GetAlbum", accessToken, "/albums/"+url.PathEscape(id), query, &album)
+	if err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+// GetAlbumTracks returns simplified tracks; their Album field is empty.
+func (c *Client) GetAlbumTracks(ctx context.Context, accessToken, id, market string, offset, limit int) (*Paging[Track], error) {
+	var page Paging[Track]
+	err := c.get(ctx, "GetAlbumTracks", accessToken, "/albums/"+url.PathEscape(id)+"/tracks", pageQuery(market, offset, limit), &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func pageQuery(market string, offset, limit int) url.Values {
+	query := url.Values{}
+	query.Set("offset", strconv.Itoa(offset))
+	query.Set("limit", strconv.Itoa(limit))
+	if market != "" {
+		query.Set("market", market)
+	}
+	return query
+}
+
+func (c *Client) GetArtistTopTracks(ctx context.Context, accessToken, id, market string) ([]Track, error) {
+	query := url.Values{}
+	if market != "" {
+		query.Set("market", market)
+	}
+
+	var response TopTracksResponse
+	err := c.get(ctx, "GetArtistTopTracks", accessToken, "/artists/"+url.PathEscape(id)+"/top-tracks", query, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Tracks, nil
+}
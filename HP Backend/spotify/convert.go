@@ -0,0 +1,96 @@
+package spotify
+
+import "houseparty.com/models"
+
+// ThumbnailWidth is the album art width rooms display next to a song.
+const ThumbnailWidth = 64
+
+func ToSong(track Track) models.Song {
+	artists := make([]string, 0, len(track.Artists))
+	artistIds := make([]string, 0, len(track.Artists))
+	for _, artist := range track.Artists {
+		if artist.Name != "" {
+			artists = append(artists, artist.Name)
+			artistIds = append(artistIds, artist.ID)
+		}
+	}
+
+	image := PickImage(track.Album.Images, ThumbnailWidth)
+
+	return models.Song{
+		Id:        track.ID,
+		URI:       track.URI,
+		Name:      track.Name,
+		Artists:   artists,
+		ArtistIds: artistIds,
+		Album:     track.Album.Name,
+		AlbumId:   track.Album.ID,
+		Image: models.Image{
+			URL:    image.URL,
+			Width:  image.Width,
+			Height: image.Height,
+		},
+		DurationMs:  track.DurationMs,
+		Explicit:    track.Explicit,
+		ExternalURL: track.ExternalURLs.Spotify,
+		PreviewURL:  track.PreviewURL,
+	}
+}
+
+// ToSongs converts tracks, dropping entries Spotify returns as null, without
+// an id (local tracks) or as unplayable in the requested market.
+func ToSongs(tracks []Track) []models.Song {
+	songs := make([]models.Song, 0, len(tracks))
+	for _, track := range tracks {
+		if track.ID == "" || (track.IsPlayable != nil && !*track.IsPlayable) {
+			continue
+		}
+		songs = append(songs, ToSong(track))
+	}
+	return songs
+}
+
+// WithAlbum fills in the album of simplified tracks, which Spotify returns
+// without it when listing an album's tracks.
+func WithAlbum(tracks []Track, album Album) []Track {
+	for i := range tracks {
+		tracks[i].Album = album
+	}
+	return tracks
+}
+
+// PickImage returns the smallest image that is at least width pixels wide,
+// or the largest available image when none is big enough. Images with an
+// unknown width are only used as a last resort.
+func PickImage(images []Image, width int) Image {
+	var best, largest, unknown *Image
+	for i := range images {
+		image := &images[i]
+		if image.URL == "" {
+			continue
+		}
+		if image.Width == 0 {
+			if unknown == nil {
+				unknown = image
+			}
+			continue
+		}
+		if image.Width >= width && (best == nil || image.Width < best.Width) {
+			best = image
+		}
+		if largest == nil || image.Width > largest.Width {
+			largest = image
+		}
+	}
+
+	switch {
+	case best != nil:
+		return *best
+	case largest != nil:
+		return *largest
+	case unknown != nil:
+		return *unknown
+	default:
+		return Image{}
+	}
+}
@@ -0,0 +1,172 @@
+package spotify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"houseparty.com/models"
+)
+
+// fixtureClient returns a client whose API answers every request with the
+// recorded response in testdata/name.
+func fixtureClient(t *testing.T, name string) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeFile(w, r, filepath.Join("testdata", name))
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, server.Client())
+}
+
+func searchSongs(c *Client) ([]models.Song, error) {
+	response, err := c.SearchTracks(context.Background(), "token", "daft punk", "GB", 0, 5)
+	if err != nil {
+		return nil, err
+	}
+	return ToSongs(response.Tracks.Items), nil
+}
+
+func getSong(c *Client) ([]models.Song, error) {
+	track, err := c.GetTrack(context.Background(), "token", "4R2kfaDFhslZEMJqAFNpdd")
+	if err != nil {
+		return nil, err
+	}
+	return []models.Song{ToSong(*track)}, nil
+}
+
+func TestConvertFixtures(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		fetch   func(*Client) ([]models.Song, error)
+		want    []models.Song
+		wantErr string
+	}{
+		{
+			name:    "search drops null, local and unplayable tracks",
+			fixture: "search_tracks.json",
+			fetch:   searchSongs,
+			want: []models.Song{
+				{
+					Id:          "69kOkLUCkxIZYexIgSG8rq",
+					URI:         "spotify:track:69kOkLUCkxIZYexIgSG8rq",
+					Name:        "Get Lucky (feat. Pharrell Williams and Nile Rodgers)",
+					Artists:     []string{"Daft Punk", "Pharrell Williams", "Nile Rodgers"},
+					ArtistIds:   []string{"4tZwfgrHOc3mvqYlEYSvVi", "2RdwBSPQiwcmiDo9kixcl8", "3yDIp0kaq9EFKe07X1X2rz"},
+					Album:       "Random Access Memories",
+					AlbumId:     "4m2880jivSbbyEGAKfITCa",
+					Image:       models.Image{URL: "https://i.scdn.co/image/small", Width: 64, Height: 64},
+					DurationMs:  369626,
+					ExternalURL: "https://open.spotify.com/track/69kOkLUCkxIZYexIgSG8rq",
+					PreviewURL:  "https://p.scdn.co/mp3-preview/get-lucky",
+				},
+				{
+					Id:         "2VEZx7NWsZ1D0eJ4uv5Fym",
+					URI:        "spotify:track:2VEZx7NWsZ1D0eJ4uv5Fym",
+					Name:       "Harder, Better, Faster, Stronger",
+					Artists:    []string{"Daft Punk"},
+					ArtistIds:  []string{"4tZwfgrHOc3mvqYlEYSvVi"},
+					Album:      "Discovery",
+					AlbumId:    "2noRn2Aes5aoNVsU6iWThc",
+					DurationMs: 224693,
+					Explicit:   true,
+				},
+			},
+		},
+		{
+			name:    "track with null image sizes and no external urls",
+			fixture: "track_partial.json",
+			fetch:   getSong,
+			want: []models.Song{{
+				Id:         "4R2kfaDFhslZEMJqAFNpdd",
+				URI:        "spotify:track:4R2kfaDFhslZEMJqAFNpdd",
+				Name:       "willow",
+				Artists:    []string{"Taylor Swift"},
+				ArtistIds:  []string{"06HL4z0CvFAxyc27GXpf02"},
+				Album:      "Evermore",
+				AlbumId:    "1ATL5GLyefJaxhQzSPVrLX",
+				Image:      models.Image{URL: "https://i.scdn.co/image/unknown-size"},
+				DurationMs: 245000,
+			}},
+		},
+		{
+			name:    "truncated body",
+			fixture: "search_truncated.json",
+			fetch:   searchSongs,
+			wantErr: "invalid body",
+		},
+		{
+			name:    "fields with the wrong type",
+			fixture: "search_wrong_types.json",
+			fetch:   searchSongs,
+			wantErr: "invalid body",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			songs, err := test.fetch(fixtureClient(t, test.fixture))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(songs, test.want) {
+				t.Errorf("songs =\n%+v\nwant\n%+v", songs, test.want)
+			}
+		})
+	}
+}
+
+func TestToSongWithoutAlbumOrArtists(t *testing.T) {
+	song := ToSong(Track{ID: "id", URI: "spotify:track:id", Name: "Untitled"})
+
+	if song.Image != (models.Image{}) {
+		t.Errorf("Image = %+v, want none", song.Image)
+	}
+	if song.Artists == nil || len(song.Artists) != 0 {
+		t.Errorf("Artists = %#v, want an empty list", song.Artists)
+	}
+}
+
+func TestPickImage(t *testing.T) {
+	small := Image{URL: "small", Width: 64, Height: 64}
+	medium := Image{URL: "medium", Width: 300, Height: 300}
+	large := Image{URL: "large", Width: 640, Height: 640}
+	unknown := Image{URL: "unknown"}
+
+	tests := []struct {
+		name   string
+		images []Image
+		width  int
+		want   Image
+	}{
+		{name: "no images", images: nil, width: 64, want: Image{}},
+		{name: "empty list", images: []Image{}, width: 64, want: Image{}},
+		{name: "images without urls", images: []Image{{Width: 640}, {Width: 64}}, width: 64, want: Image{}},
+		{name: "exact match", images: []Image{large, medium, small}, width: 64, want: small},
+		{name: "smallest big enough", images: []Image{large, small, medium}, width: 100, want: medium},
+		{name: "none big enough uses largest", images: []Image{small, medium}, width: 1000, want: medium},
+		{name: "single image", images: []Image{large}, width: 64, want: large},
+		{name: "known width preferred over unknown", images: []Image{unknown, small}, width: 300, want: small},
+		{name: "only unknown width", images: []Image{unknown}, width: 64, want: unknown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := PickImage(test.images, test.width); got != test.want {
+				t.Errorf("PickImage = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package spotify
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	LinkTrack    = "track"
+	LinkAlbum    = "album"
+	LinkPlaylist = "playlist"
+)
+
+var ErrInvalidLink = errors.New("not a Spotify track, album or playlist link")
+
+type Link struct {
+	Kind string
+	ID   string
+}
+
+// ParseLink accepts spotify:<kind>:<id> URIs and open.spotify.com URLs,
+// including localised (/intl-xx/) and embed paths.
+func ParseLink(raw string) (Link, error) {
+	raw = strings.TrimSpace(raw)
+
+	if rest, ok := strings.CutPrefix(raw, "spotify:"); ok {
+		parts := strings.Split(rest, ":")
+		if len(parts) < 2 {
+			return Link{}, ErrInvalidLink
+		}
+		// Older playlist URIs look like spotify:user:<name>:playlist:<id>.
+		return newLink(parts[len(parts)-2], parts[len(parts)-1])
+	}
+
+	parsed, err := parseLinkURL(raw)
+	if err != nil || parsed.Hostname() != "open.spotify.com" {
+		return Link{}, ErrInvalidLink
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(parsed.Path, "/") {
+		if segment == "" || segment == "embed" || strings.HasPrefix(segment, "intl-") {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) < 2 {
+		return Link{}, ErrInvalidLink
+	}
+	return newLink(segments[len(segments)-2], segments[len(segments)-1])
+}
+
+func newLink(kind, id string) (Link, error) {
+	switch kind {
+	case LinkTrack, LinkAlbum, LinkPlaylist:
+	default:
+		return Link{}, ErrInvalidLink
+	}
+
+	if id == "" {
+		return Link{}, ErrInvalidLink
+	}
+	for _, r := range id {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return Link{}, ErrInvalidLink
+		}
+	}
+	return Link{Kind: kind, ID: id}, nil
+}
+
+// URI returns the canonical spotify:<kind>:<id> form of the link.
+func (l Link) URI() string {
+	return "spotify:" + l.Kind + ":" + l.ID
+}
@@ -0,0 +1,42 @@
+package spotify
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Link
+		err  error
+	}{
+		{raw: "spotify:track:4R2kfaDFhslZEMJqAFNpdd", want: Link{Kind: LinkTrack, ID: "4R2kfaDFhslZEMJqAFNpdd"}},
+		{raw: "  spotify:album:2noRn2Aes5aoNVsU6iWThc ", want: Link{Kind: LinkAlbum, ID: "2noRn2Aes5aoNVsU6iWThc"}},
+		{raw: "spotify:user:alice:playlist:37i9dQZF1DXcBWIGoYBM5M", want: Link{Kind: LinkPlaylist, ID: "37i9dQZF1DXcBWIGoYBM5M"}},
+		{raw: "https://open.spotify.com/track/4R2kfaDFhslZEMJqAFNpdd", want: Link{Kind: LinkTrack, ID: "4R2kfaDFhslZEMJqAFNpdd"}},
+		{raw: "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M?si=abc123", want: Link{Kind: LinkPlaylist, ID: "37i9dQZF1DXcBWIGoYBM5M"}},
+		{raw: "https://open.spotify.com/intl-de/album/2noRn2Aes5aoNVsU6iWThc", want: Link{Kind: LinkAlbum, ID: "2noRn2Aes5aoNVsU6iWThc"}},
+		{raw: "https://open.spotify.com/embed/track/4R2kfaDFhslZEMJqAFNpdd", want: Link{Kind: LinkTrack, ID: "4R2kfaDFhslZEMJqAFNpdd"}},
+		{raw: "open.spotify.com/track/4R2kfaDFhslZEMJqAFNpdd", want: Link{Kind: LinkTrack, ID: "4R2kfaDFhslZEMJqAFNpdd"}},
+		{raw: "https://evil.example/track/4R2kfaDFhslZEMJqAFNpdd", err: ErrInvalidLink},
+		{raw: "https://open.spotify.com.evil.example/track/4R2kfaDFhslZEMJqAFNpdd", err: ErrInvalidLink},
+		{raw: "https://open.spotify.com/artist/0k17h0D3J5VfsdmQ1iZtE9", err: ErrInvalidLink},
+		{raw: "https://open.spotify.com/track/", err: ErrInvalidLink},
+		{raw: "spotify:track:../../me", err: ErrInvalidLink},
+		{raw: "spotify:track", err: ErrInvalidLink},
+		{raw: "daft punk", err: ErrInvalidLink},
+	}
+
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			got, err := ParseLink(test.raw)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error = %v, want %v", err, test.err)
+			}
+			if got != test.want {
+				t.Errorf("link = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
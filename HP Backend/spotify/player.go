@@ -0,0 +1,50 @@
+package spotify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+func (c *Client) GetDevices(ctx context.Context, accessToken string) ([]Device, error) {
+	var response DevicesResponse
+	err := c.get(ctx, "GetDevices", accessToken, "/me/player/devices", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Devices, nil
+}
+
+// GetPlaybackState returns nil without an error when nothing is playing on
+// any of the user's devices.
+func (c *Client) GetPlaybackState(ctx context.Context, accessToken string) (*PlaybackState, error) {
+	var state PlaybackState
+	status, err := c.do(ctx, http.MethodGet, "GetPlaybackState", accessToken, "/me/player", nil, nil, &state)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNoContent {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (c *Client) Play(ctx context.Context, accessToken, deviceId string, uris []string, positionMs int) error {
+	query := url.Values{}
+	if deviceId != "" {
+		query.Set("device_id", deviceId)
+	}
+
+	_, err := c.do(ctx, http.MethodPut, "Play", accessToken, "/me/player/play", query, playRequest{URIs: uris, PositionMs: positionMs}, nil)
+	return err
+}
+
+func (c *Client) Pause(ctx context.Context, accessToken, deviceId string) error {
+	query := url.Values{}
+	if deviceId != "" {
+		query.Set("device_id", deviceId)
+	}
+
+	_, err := c.do(ctx, http.MethodPut, "Pause", accessToken, "/me/player/pause", query, nil, nil)
+	return err
+}
@@ -0,0 +1,33 @@
+package spotify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// MaxTracksPerRequest is the most URIs Spotify accepts when adding tracks.
+const MaxTracksPerRequest = 100
+
+func (c *Client) CreatePlaylist(ctx context.Context, accessToken, name, description string, public bool) (*Playlist, error) {
+	var playlist Playlist
+	request := createPlaylistRequest{Name: name, Description: description, Public: public}
+	_, err := c.do(ctx, http.MethodPost, "CreatePlaylist", accessToken, "/me/playlists", nil, request, &playlist)
+	if err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+func (c *Client) AddPlaylistTracks(ctx context.Context, accessToken, playlistId string, uris []string) error {
+	for start := 0; start < len(uris); start += MaxTracksPerRequest {
+		end := min(start+MaxTracksPerRequest, len(uris))
+		request := addTracksRequest{URIs: uris[start:end]}
+
+		_, err := c.do(ctx, http.MethodPost, "AddPlaylistTracks", accessToken, "/playlists/"+url.PathEscape(playlistId)+"/tracks", nil, request, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"houseparty.com/metrics"
+)
+
+const maxShortLinkRedirects = 5
+
+var (
+	shortLinkHosts = map[string]bool{
+		"spotify.link":     true,
+		"spoti.fi":         true,
+		"spotify.app.link": true,
+	}
+	openURLPattern = regexp.MustCompile(`https://open\.spotify\.com/[^"'\s<>]+`)
+)
+
+// IsShortLink reports whether raw is a shortened share link, which has to be
+// resolved with ResolveShortLink before it can be parsed.
+func IsShortLink(raw string) bool {
+	parsed, err := parseLinkURL(raw)
+	return err == nil && shortLinkHosts[parsed.Hostname()]
+}
+
+// ResolveShortLink follows a shortened share link to the open.spotify.com
+// URL behind it. Only known short link hosts are contacted. Some of them
+// answer browsers with an HTML page instead of a redirect, so the page is
+// searched for the target URL as well.
+func (c *Client) ResolveShortLink(ctx context.Context, raw string) (Link, error) {
+	parsed, err := parseLinkURL(raw)
+	if err != nil || !shortLinkHosts[parsed.Hostname()] {
+		return Link{}, ErrInvalidLink
+	}
+	parsed.Scheme = "https"
+
+	client := *c.HTTPClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Hostname() == "open.spotify.com" {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= maxShortLinkRedirects || !shortLinkHosts[req.URL.Hostname()] {
+			return errors.New("short link redirects somewhere unexpected")
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return Link{}, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	metrics.ObserveSpotifyCall("ResolveShortLink", start, resp)
+	if err != nil {
+		return Link{}, err
+	}
+	defer resp.Body.Close()
+
+	if location := resp.Header.Get("Location"); location != "" {
+		return ParseLink(location)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Link{}, newAPIError("ResolveShortLink", resp)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+	if err != nil {
+		return Link{}, err
+	}
+	target := openURLPattern.Find(body)
+	if target == nil {
+		return Link{}, ErrInvalidLink
+	}
+	return ParseLink(strings.ReplaceAll(string(target), "&amp;", "&"))
+}
+
+func parseLinkURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	return url.Parse(raw)
+}
@@ -0,0 +1,138 @@
+package spotify
+
+type Image struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type ExternalURLs struct {
+	Spotify string `json:"spotify"`
+}
+
+type Artist struct {
+	ID           string       `json:"id"`
+	URI          string       `json:"uri"`
+	Name         string       `json:"name"`
+	ExternalURLs ExternalURLs `json:"external_urls"`
+}
+
+type Album struct {
+	ID           string       `json:"id"`
+	URI          string       `json:"uri"`
+	Name         string       `json:"name"`
+	AlbumType    string       `json:"album_type"`
+	ReleaseDate  string       `json:"release_date"`
+	Artists      []Artist     `json:"artists"`
+	Images       []Image      `json:"images"`
+	ExternalURLs ExternalURLs `json:"external_urls"`
+}
+
+// LinkedTrack is the track that was asked for when Spotify relinks it to
+// another release that is playable in the user's market.
+type LinkedTrack struct {
+	ID  string `json:"id"`
+	URI string `json:"uri"`
+}
+
+type Track struct {
+	ID           string       `json:"id"`
+	URI          string       `json:"uri"`
+	LinkedFrom   *LinkedTrack `json:"linked_from,omitempty"`
+	Name         string       `json:"name"`
+	Artists      []Artist     `json:"artists"`
+	Album        Album        `json:"album"`
+	DurationMs   int          `json:"duration_ms"`
+	Explicit     bool         `json:"explicit"`
+	IsPlayable   *bool        `json:"is_playable,omitempty"`
+	ExternalURLs ExternalURLs `json:"external_urls"`
+	PreviewURL   string       `json:"preview_url"`
+}
+
+type Paging[T any] struct {
+	Href     string `json:"href"`
+	Items    []T    `json:"items"`
+	Limit    int    `json:"limit"`
+	Offset   int    `json:"offset"`
+	Total    int    `json:"total"`
+	Next     string `json:"next"`
+	Previous string `json:"previous"`
+}
+
+type SearchResponse struct {
+	Tracks Paging[Track] `json:"tracks"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type Device struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	Type             string `json:"type"`
+	IsActive         bool   `json:"is_active"`
+	IsRestricted     bool   `json:"is_restricted"`
+	VolumePercent    *int   `json:"volume_percent"`
+	SupportsVolume   bool   `json:"supports_volume"`
+	IsPrivateSession bool   `json:"is_private_session"`
+}
+
+type DevicesResponse struct {
+	Devices []Device `json:"devices"`
+}
+
+type PlaybackState struct {
+	Device     Device `json:"device"`
+	IsPlaying  bool   `json:"is_playing"`
+	ProgressMs int    `json:"progress_ms"`
+	Item       *Track `json:"item"`
+}
+
+type playRequest struct {
+	URIs       []string `json:"uris"`
+	PositionMs int      `json:"position_ms"`
+}
+
+type PlaylistItem struct {
+	IsLocal bool   `json:"is_local"`
+	Track   *Track `json:"track"`
+}
+
+type FullAlbum struct {
+	Album
+	Tracks Paging[Track] `json:"tracks"`
+}
+
+type Playlist struct {
+	ID           string       `json:"id"`
+	URI          string       `json:"uri"`
+	Name         string       `json:"name"`
+	Public       bool         `json:"public"`
+	ExternalURLs ExternalURLs `json:"external_urls"`
+}
+
+type createPlaylistRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+}
+
+type addTracksRequest struct {
+	URIs []string `json:"uris"`
+}
+
+type TopTracksResponse struct {
+	Tracks []Track `json:"tracks"`
+}
+
+// User is the profile of the account behind an access token. Email is only
+// filled in when the user-read-email scope was granted.
+type User struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+}
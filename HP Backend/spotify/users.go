@@ -0,0 +1,12 @@
+package spotify
+
+import "context"
+
+func (c *Client) GetCurrentUser(ctx context.Context, accessToken string) (*User, error) {
+	var user User
+	err := c.get(ctx, "GetCurrentUser", accessToken, "/me", nil, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -8,8 +8,16 @@ import (
 var DB *sql.DB
 
 func InitDB() {
+	OpenDB("api.db")
+}
+
+// OpenDB opens the database at path and brings its tables up to date.
+func OpenDB(path string) {
 	var err error
-	DB, err = sql.Open("sqlite", "api.db")
+	// Writers wait for each other instead of failing with SQLITE_BUSY, and
+	// transactions take the write lock up front so a read inside one cannot
+	// go stale before its write.
+	DB, err = sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_txlock=immediate")
 
 	if err != nil {
 		panic("could not connect to databse")
@@ -18,7 +26,23 @@ func InitDB() {
 	DB.SetMaxOpenConns(10)
 	DB.SetMaxIdleConns(5)
 
+	dropOutdatedTrackCache()
 	createTables()
+	migrateTables()
+}
+
+// dropOutdatedTrackCache removes a track cache from before tracks were kept
+// per market. It only holds copies of provider data, so createTables starts
+// an empty one in its place.
+func dropOutdatedTrackCache() {
+	if !columnExists("track_cache", "track_id") || columnExists("track_cache", "market") {
+		return
+	}
+
+	_, err := DB.Exec(`DROP TABLE track_cache`)
+	if err != nil {
+		panic(err)
+	}
 }
 
 func createTables() {
@@ -72,4 +96,200 @@ func createTables() {
 	if err != nil {
 		panic(err)
 	}
+
+	createTrackCacheTable := `
+	CREATE TABLE IF NOT EXISTS track_cache (
+		provider TEXT NOT NULL,
+		market TEXT NOT NULL,
+		track_id TEXT NOT NULL,
+		song TEXT NOT NULL,
+		cached_at INTEGER NOT NULL,
+		PRIMARY KEY (provider, market, track_id)
+	)
+	`
+	_, err = DB.Exec(createTrackCacheTable)
+
+	if err != nil {
+		panic(err)
+	}
+
+	createOAuthStatesTable := `
+	CREATE TABLE IF NOT EXISTS oauth_states (
+		state TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		code_verifier TEXT NOT NULL,
+		expires_at INTEGER NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	)
+	`
+	_, err = DB.Exec(createOAuthStatesTable)
+
+	if err != nil {
+		panic(err)
+	}
+
+	createRoomHistoryTable := `
+	CREATE TABLE IF NOT EXISTS room_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		room_id TEXT NOT NULL,
+		song TEXT NOT NULL,
+		played_at DATETIME NOT NULL,
+		skipped BOOLEAN NOT NULL DEFAULT false,
+		FOREIGN KEY (room_id) REFERENCES rooms(id)
+	)
+	`
+	_, err = DB.Exec(createRoomHistoryTable)
+
+	if err != nil {
+		panic(err)
+	}
+
+	createLocalTracksTable := `
+	CREATE TABLE IF NOT EXISTS local_tracks (
+		id TEXT PRIMARY KEY,
+		owner_id INTEGER NOT NULL,
+		format TEXT NOT NULL,
+		title TEXT NOT NULL,
+		artists TEXT NOT NULL,
+		album TEXT NOT NULL,
+		year INTEGER NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		cover_mime TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		uploaded_at DATETIME NOT NULL,
+		FOREIGN KEY (owner_id) REFERENCES users(id)
+	)
+	`
+	_, err = DB.Exec(createLocalTracksTable)
+
+	if err != nil {
+		panic(err)
+	}
+
+	createSessionsTable := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		revoked_at DATETIME NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	)
+	`
+	_, err = DB.Exec(createSessionsTable)
+
+	if err != nil {
+		panic(err)
+	}
+
+	createRefreshTokensTable := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		token_hash TEXT PRIMARY KEY,
+		session_id TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME NULL,
+		FOREIGN KEY (session_id) REFERENCES sessions(id)
+	)
+	`
+	_, err = DB.Exec(createRefreshTokensTable)
+
+	if err != nil {
+		panic(err)
+	}
+
+	createRoomTicketsTable := `
+	CREATE TABLE IF NOT EXISTS room_tickets (
+		ticket_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		session_id TEXT NOT NULL,
+		room_id TEXT NOT NULL,
+		expires_at INTEGER NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	)
+	`
+	_, err = DB.Exec(createRoomTicketsTable)
+
+	if err != nil {
+		panic(err)
+	}
+
+	createUserTokensTable := `
+	CREATE TABLE IF NOT EXISTS user_tokens (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		purpose TEXT NOT NULL,
+		expires_at INTEGER NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	)
+	`
+	_, err = DB.Exec(createUserTokensTable)
+
+	if err != nil {
+		panic(err)
+	}
+
+	createLoginAttemptsTable := `
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		key TEXT PRIMARY KEY,
+		failures INTEGER NOT NULL,
+		locked_until INTEGER NOT NULL DEFAULT 0,
+		last_failure INTEGER NOT NULL
+	)
+	`
+	_, err = DB.Exec(createLoginAttemptsTable)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+func migrateTables() {
+	addColumnIfMissing("rooms", "provider", "TEXT NOT NULL DEFAULT 'spotify'")
+	addColumnIfMissing("rooms", "playback_mode", "TEXT NOT NULL DEFAULT 'host'")
+	addColumnIfMissing("rooms", "device_id", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfMissing("rooms", "block_explicit", "BOOLEAN NOT NULL DEFAULT false")
+	addColumnIfMissing("rooms", "preview_clips", "BOOLEAN NOT NULL DEFAULT false")
+	addColumnIfMissing("users", "email_verified", "BOOLEAN NOT NULL DEFAULT false")
+	addColumnIfMissing("users", "is_admin", "BOOLEAN NOT NULL DEFAULT false")
+	addColumnIfMissing("users", "spotify_id", "TEXT NOT NULL DEFAULT ''")
+
+	_, err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS users_spotify_id ON users(spotify_id) WHERE spotify_id != ''`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func addColumnIfMissing(table, column, definition string) {
+	if columnExists(table, column) {
+		return
+	}
+
+	_, err := DB.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func columnExists(table, column string) bool {
+	rows, err := DB.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		panic(err)
+	}
+
+	exists := false
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue sql.NullString
+
+		err = rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey)
+		if err != nil {
+			rows.Close()
+			panic(err)
+		}
+		if name == column {
+			exists = true
+		}
+	}
+	rows.Close()
+	return exists
 }
@@ -4,11 +4,33 @@ const RetrieveHashPasswordQuery = "SELECT id, username, email, password FROM use
 
 const SaveUserQuery = `INSERT INTO users(email, password, username) VALUES(?, ?, ?)`
 
-const SaveRoomQuery = `INSERT INTO rooms(id, name, description, host_id, public, created_at) VALUES(?, ?, ?, ?, ?, ?)`
+const SaveRoomQuery = `INSERT INTO rooms(id, name, description, host_id, public, created_at, provider, playback_mode, block_explicit, preview_clips) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-const GetRoomByIdQuery = `SELECT id, name, description, host_id, public, created_at FROM rooms WHERE id = ?`
+const GetRoomByIdQuery = `SELECT id, name, description, host_id, public, created_at, provider, playback_mode, block_explicit, preview_clips, device_id FROM rooms WHERE id = ?`
 
-const GetUserByIdQuery = `SELECT id, username, email, spotify_connected FROM users WHERE id = ?`
+const UpdateRoomDeviceQuery = `UPDATE rooms SET device_id = ? WHERE id = ?`
+
+const GetUserByIdQuery = `SELECT id, username, email, spotify_connected, email_verified, is_admin, spotify_id FROM users WHERE id = ?`
+
+const GetUserByEmailQuery = `SELECT id, username, email, spotify_connected, email_verified, is_admin, spotify_id FROM users WHERE email = ?`
+
+const GetUserBySpotifyIdQuery = `SELECT id, username, email, spotify_connected, email_verified, is_admin, spotify_id FROM users WHERE spotify_id = ?`
+
+// SaveSpotifyUserQuery creates an account that signs in through Spotify only,
+// so it has no password hash.
+const SaveSpotifyUserQuery = `INSERT INTO users(email, password, username, spotify_id) VALUES(?, '', ?, ?)`
+
+const LinkSpotifyQuery = `UPDATE users SET spotify_id = ? WHERE id = ?`
+
+const UnlinkSpotifyQuery = `UPDATE users SET spotify_id = '' WHERE id = ?`
+
+const UpdatePasswordQuery = `UPDATE users SET password = ? WHERE id = ?`
+
+const GetPasswordHashQuery = `SELECT password FROM users WHERE id = ?`
+
+const UpdateProfileQuery = `UPDATE users SET username = ?, email = ?, email_verified = ? WHERE id = ?`
+
+const VerifyEmailQuery = `UPDATE users SET email_verified = true WHERE id = ?`
 
 const DeleteRoomQuery = `DELETE FROM rooms WHERE id = ?`
 
@@ -28,9 +50,12 @@ SET access_token = ?,
     token_type = ?, 
     scope = ?, 
     expires_in = ?, 
+    refresh_token = ?, 
     time_issued = ? 
 WHERE user_id = ?`
 
+const ExpiringTokensQuery = `SELECT user_id FROM token WHERE time_issued + expires_in <= ?`
+
 const PublicRoomsQuery = `
 SELECT 
     rooms.id, 
@@ -39,6 +64,10 @@ SELECT
     rooms.host_id, 
     rooms.public, 
     rooms.created_at, 
+    rooms.provider, 
+    rooms.playback_mode, 
+    rooms.block_explicit, 
+    rooms.preview_clips, 
     users.username
 FROM 
     rooms 
@@ -59,6 +88,10 @@ SELECT
     rooms.host_id, 
     rooms.public, 
     rooms.created_at, 
+    rooms.provider, 
+    rooms.playback_mode, 
+    rooms.block_explicit, 
+    rooms.preview_clips, 
     users.username
 FROM 
     rooms 
@@ -78,4 +111,139 @@ const CheckInUseQuery = `
 	FROM users
 	WHERE username = ? OR email = ?
 	LIMIT 1;
-`
\ No newline at end of file
+`
+
+const GetCachedTrackQuery = `SELECT song FROM track_cache WHERE provider = ? AND market = ? AND track_id = ?`
+
+const SaveCachedTrackQuery = `
+INSERT INTO track_cache(provider, market, track_id, song, cached_at) 
+VALUES(?, ?, ?, ?, ?)
+ON CONFLICT(provider, market, track_id) DO UPDATE SET song = excluded.song, cached_at = excluded.cached_at`
+
+const DeleteCachedTrackQuery = `DELETE FROM track_cache WHERE provider = ? AND track_id = ?`
+
+const DeleteProviderCachedTracksQuery = `DELETE FROM track_cache WHERE provider = ?`
+
+const CountCachedTracksQuery = `SELECT COUNT(*) FROM track_cache`
+
+const AllTokenSecretsQuery = `SELECT id, access_token, refresh_token FROM token`
+
+const UpdateTokenSecretsQuery = `UPDATE token SET access_token = ?, refresh_token = ? WHERE id = ? AND access_token = ? AND refresh_token = ?`
+
+const SaveOAuthStateQuery = `INSERT INTO oauth_states(state, user_id, code_verifier, expires_at) VALUES(?, ?, ?, ?)`
+
+// ConsumeOAuthStateQuery deletes and reads the state in one statement, so
+// only one callback can redeem it.
+const ConsumeOAuthStateQuery = `DELETE FROM oauth_states WHERE state = ? RETURNING user_id, code_verifier, expires_at`
+
+const DeleteExpiredOAuthStatesQuery = `DELETE FROM oauth_states WHERE expires_at < ?`
+
+const SaveHistoryEntryQuery = `INSERT INTO room_history(room_id, song, played_at) VALUES(?, ?, ?)`
+
+const MarkHistoryEntrySkippedQuery = `UPDATE room_history SET skipped = true WHERE id = ?`
+
+const RoomHistoryQuery = `SELECT id, room_id, song, played_at, skipped FROM room_history WHERE room_id = ? ORDER BY played_at, id`
+
+const DeleteRoomHistoryQuery = `DELETE FROM room_history WHERE room_id = ?`
+
+// SaveLocalTrackQuery only inserts the track while the owner's library stays
+// within quota, so parallel uploads cannot overshoot it. The last three
+// parameters are the owner id, the track size and the quota.
+const SaveLocalTrackQuery = `
+INSERT INTO local_tracks(id, owner_id, format, title, artists, album, year, duration_ms, cover_mime, size, uploaded_at) 
+SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? 
+WHERE (SELECT COALESCE(SUM(size), 0) FROM local_tracks WHERE owner_id = ?) + ? <= ?`
+
+const GetLocalTrackQuery = `SELECT id, owner_id, format, title, artists, album, year, duration_ms, cover_mime, size, uploaded_at FROM local_tracks WHERE id = ?`
+
+const SearchLocalTracksQuery = `
+SELECT id, owner_id, format, title, artists, album, year, duration_ms, cover_mime, size, uploaded_at, COUNT(*) OVER() 
+FROM local_tracks 
+WHERE owner_id = ? 
+    AND (title LIKE ? ESCAPE '\' OR artists LIKE ? ESCAPE '\' OR album LIKE ? ESCAPE '\') 
+    AND artists LIKE ? ESCAPE '\' 
+    AND album LIKE ? ESCAPE '\' 
+    AND year BETWEEN ? AND ? 
+ORDER BY title, id 
+LIMIT ? OFFSET ?`
+
+const DeleteLocalTrackQuery = `DELETE FROM local_tracks WHERE id = ?`
+
+const SaveSessionQuery = `INSERT INTO sessions(id, user_id, created_at) VALUES(?, ?, ?)`
+
+const GetSessionQuery = `SELECT id, user_id, created_at, revoked_at FROM sessions WHERE id = ?`
+
+const RevokeSessionQuery = `UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`
+
+const SaveRefreshTokenQuery = `INSERT INTO refresh_tokens(token_hash, session_id, expires_at) VALUES(?, ?, ?)`
+
+const GetRefreshTokenQuery = `SELECT token_hash, session_id, expires_at, used_at FROM refresh_tokens WHERE token_hash = ?`
+
+const MarkRefreshTokenUsedQuery = `UPDATE refresh_tokens SET used_at = ? WHERE token_hash = ? AND used_at IS NULL`
+
+const DeleteExpiredRefreshTokensQuery = `DELETE FROM refresh_tokens WHERE expires_at < ?`
+
+const SaveRoomTicketQuery = `INSERT INTO room_tickets(ticket_hash, user_id, session_id, room_id, expires_at) VALUES(?, ?, ?, ?, ?)`
+
+const GetRoomTicketQuery = `SELECT user_id, session_id, room_id, expires_at FROM room_tickets WHERE ticket_hash = ?`
+
+const DeleteRoomTicketQuery = `DELETE FROM room_tickets WHERE ticket_hash = ?`
+
+const DeleteExpiredRoomTicketsQuery = `DELETE FROM room_tickets WHERE expires_at < ?`
+
+const RevokeUserSessionsQuery = `UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`
+
+const RevokeOtherSessionsQuery = `UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id != ? AND revoked_at IS NULL`
+
+const SaveUserTokenQuery = `INSERT INTO user_tokens(token_hash, user_id, purpose, expires_at) VALUES(?, ?, ?, ?)`
+
+const GetUserTokenQuery = `SELECT user_id, purpose, expires_at FROM user_tokens WHERE token_hash = ?`
+
+const DeleteUserTokenQuery = `DELETE FROM user_tokens WHERE token_hash = ?`
+
+const DeleteUserTokensQuery = `DELETE FROM user_tokens WHERE user_id = ? AND purpose = ?`
+
+const DeleteExpiredUserTokensQuery = `DELETE FROM user_tokens WHERE expires_at < ?`
+
+const HostedRoomIdsQuery = `SELECT id FROM rooms WHERE host_id = ?`
+
+const OwnedLocalTracksQuery = `SELECT id, format FROM local_tracks WHERE owner_id = ?`
+
+// DeleteUserQueries remove a user and everything that references them. Each
+// takes the user id, and they run in order inside one transaction.
+var DeleteUserQueries = []string{
+	`DELETE FROM room_history WHERE room_id IN (SELECT id FROM rooms WHERE host_id = ?)`,
+	`DELETE FROM rooms WHERE host_id = ?`,
+	`DELETE FROM local_tracks WHERE owner_id = ?`,
+	`DELETE FROM refresh_tokens WHERE session_id IN (SELECT id FROM sessions WHERE user_id = ?)`,
+	`DELETE FROM sessions WHERE user_id = ?`,
+	`DELETE FROM room_tickets WHERE user_id = ?`,
+	`DELETE FROM user_tokens WHERE user_id = ?`,
+	`DELETE FROM oauth_states WHERE user_id = ?`,
+	`DELETE FROM login_attempts WHERE key = 'account:' || ?`,
+	DeleteTokenQuery,
+	`DELETE FROM users WHERE id = ?`,
+}
+
+const GetLoginAttemptsQuery = `SELECT failures, locked_until, last_failure FROM login_attempts WHERE key = ?`
+
+const SaveLoginAttemptsQuery = `
+	INSERT INTO login_attempts(key, failures, locked_until, last_failure) VALUES(?, ?, ?, ?)
+	ON CONFLICT(key) DO UPDATE SET
+		failures = excluded.failures,
+		locked_until = excluded.locked_until,
+		last_failure = excluded.last_failure
+`
+
+// RefundLoginAttemptQuery only clears the lock if it is still the one the
+// refunded attempt set, passed as the first argument.
+const RefundLoginAttemptQuery = `
+	UPDATE login_attempts SET
+		failures = MAX(failures - 1, 0),
+		locked_until = CASE WHEN locked_until = ? THEN 0 ELSE locked_until END
+	WHERE key = ?
+`
+
+const ClearLoginAttemptsQuery = `DELETE FROM login_attempts WHERE key = ?`
+
+const DeleteStaleLoginAttemptsQuery = `DELETE FROM login_attempts WHERE last_failure < ? AND locked_until < ?`
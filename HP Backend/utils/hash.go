@@ -1,6 +1,11 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -11,4 +16,11 @@ func HashPassword(password string) (string, error) {
 func CheckPasswordHash(passowrd, hashedPassword string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(passowrd))
 	return err == nil
+}
+
+// HashToken digests high-entropy tokens such as refresh tokens. Unlike
+// passwords they need no slow hash, and the digest can be looked up directly.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
\ No newline at end of file
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"time"
 
@@ -8,18 +10,35 @@ import (
 	"houseparty.com/config"
 )
 
-func GenerateToken(email, username string, userId int64) (string, error) {
+// AccessClaims are the parts of a verified access token the server relies on.
+type AccessClaims struct {
+	UserId    int64
+	SessionId string
+}
+
+func GenerateToken(email, username string, userId int64, sessionId string, expiresAt time.Time) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
         "email": email,
 		"userId": userId,
         "username": username,
-        "exp": time.Now().Add(time.Hour*2).Unix(),
+		"sid": sessionId,
+        "exp": expiresAt.Unix(),
     })
 
     return token.SignedString([]byte(config.GetJWTKey()))
 }
 
-func VerifyToken(token string) (int64, error) {
+// GenerateRandomToken returns an opaque random token for refresh tokens and
+// room tickets. Only its HashToken digest is stored.
+func GenerateRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func VerifyToken(token string) (*AccessClaims, error) {
 	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error){
 		_, ok := token.Method.(*jwt.SigningMethodHMAC)
 
@@ -30,21 +49,27 @@ func VerifyToken(token string) (int64, error) {
 		return []byte(config.GetJWTKey()), nil
 	})
 	if err != nil {
-		return 0, errors.New("parsing jwt error")
+		return nil, errors.New("parsing jwt error")
 	}
 
 	tokenIsValid := parsedToken.Valid
 	if !tokenIsValid {
-		return 0, errors.New("token invalid")
+		return nil, errors.New("token invalid")
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 	if !ok {
-		return 0, errors.New("invalid claims")
+		return nil, errors.New("invalid claims")
+	}
+
+	userId, ok := claims["userId"].(float64)
+	if !ok {
+		return nil, errors.New("invalid claims")
+	}
+	sessionId, ok := claims["sid"].(string)
+	if !ok || sessionId == "" {
+		return nil, errors.New("token has no session")
 	}
 
-	userId := claims["userId"].(float64)
-	n := int64(userId) 
-	
-	return n, nil
+	return &AccessClaims{UserId: int64(userId), SessionId: sessionId}, nil
 }
\ No newline at end of file
@@ -0,0 +1,161 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// reservedUsernames could be mistaken for the app or its staff.
+var reservedUsernames = []string{
+	"admin", "administrator", "api", "help", "host", "houseparty", "me",
+	"moderator", "null", "root", "spotify", "support", "system", "undefined",
+}
+
+// Errors maps the JSON name of each invalid field to what is wrong with it.
+type Errors map[string]string
+
+func (e Errors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field + " " + e[field]
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Init extends gin's validator with the custom tags used in `binding` struct
+// tags, and makes it report fields by their JSON names.
+func Init() error {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	rules := map[string]validator.Func{
+		"notblank":    notBlank,
+		"username":    username,
+		"notreserved": notReserved,
+		"password":    password,
+	}
+	for tag, rule := range rules {
+		if err := engine.RegisterValidation(tag, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Struct checks the binding tags of a value that did not come through gin,
+// such as a WebSocket payload.
+func Struct(value any) error {
+	err := binding.Validator.ValidateStruct(value)
+	if fields, ok := FromError(err); ok {
+		return fields
+	}
+	return err
+}
+
+// Var checks a single value against rules written like a binding tag.
+func Var(value any, rules string) error {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+	return engine.Var(value, rules)
+}
+
+// FromError returns the field errors inside err, if it is a validation error.
+func FromError(err error) (Errors, bool) {
+	var fields Errors
+	if errors.As(err, &fields) {
+		return fields, true
+	}
+
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return nil, false
+	}
+
+	fields = Errors{}
+	for _, fieldError := range invalid {
+		if _, seen := fields[fieldError.Field()]; !seen {
+			fields[fieldError.Field()] = message(fieldError)
+		}
+	}
+	return fields, true
+}
+
+func message(fieldError validator.FieldError) string {
+	unit := ""
+	if fieldError.Kind() == reflect.String {
+		unit = " characters"
+	}
+
+	switch fieldError.Tag() {
+	case "required", "required_without":
+		return "is required"
+	case "notblank":
+		return "cannot be blank"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fieldError.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fieldError.Param(), unit)
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldError.Param(), " ", ", ")
+	case "username":
+		return "can only contain letters, numbers, '.', '_' and '-'"
+	case "notreserved":
+		return "is reserved"
+	case "password":
+		return "must contain at least one letter and one number"
+	}
+	return "is not valid"
+}
+
+func notBlank(field validator.FieldLevel) bool {
+	return strings.TrimSpace(field.Field().String()) != ""
+}
+
+func username(field validator.FieldLevel) bool {
+	return usernamePattern.MatchString(field.Field().String())
+}
+
+func notReserved(field validator.FieldLevel) bool {
+	return !slices.Contains(reservedUsernames, strings.ToLower(field.Field().String()))
+}
+
+// password asks for a letter and a digit; length is left to min and max so
+// the limits show up next to the field.
+func password(field validator.FieldLevel) bool {
+	value := field.Field().String()
+	return strings.ContainsFunc(value, unicode.IsLetter) && strings.ContainsFunc(value, unicode.IsDigit)
+}
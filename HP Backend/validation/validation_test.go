@@ -0,0 +1,82 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+type signup struct {
+	Username string `json:"username" binding:"required,min=3,max=30,username,notreserved"`
+	Password string `json:"password" binding:"required,min=8,password"`
+	Name     string `json:"name" binding:"omitempty,notblank"`
+}
+
+func TestMain(m *testing.M) {
+	if err := Init(); err != nil {
+		panic(err)
+	}
+	m.Run()
+}
+
+func TestCustomRules(t *testing.T) {
+	valid := signup{Username: "erin.b-2_x", Password: "correct-horse1", Name: "Erin"}
+	tests := []struct {
+		name   string
+		change func(s *signup)
+		want   Errors
+	}{
+		{name: "valid", change: func(s *signup) {}},
+		{name: "username with a space", change: func(s *signup) { s.Username = "erin b" }, want: Errors{"username": "can only contain letters, numbers, '.', '_' and '-'"}},
+		{name: "username with a slash", change: func(s *signup) { s.Username = "erin/b" }, want: Errors{"username": "can only contain letters, numbers, '.', '_' and '-'"}},
+		{name: "username too short", change: func(s *signup) { s.Username = "er" }, want: Errors{"username": "must be at least 3 characters"}},
+		{name: "reserved username", change: func(s *signup) { s.Username = "admin" }, want: Errors{"username": "is reserved"}},
+		{name: "reserved username in capitals", change: func(s *signup) { s.Username = "Spotify" }, want: Errors{"username": "is reserved"}},
+		{name: "reserved name inside a username", change: func(s *signup) { s.Username = "admin-erin" }},
+		{name: "password without a number", change: func(s *signup) { s.Password = "correct-horse" }, want: Errors{"password": "must contain at least one letter and one number"}},
+		{name: "password without a letter", change: func(s *signup) { s.Password = "12345678" }, want: Errors{"password": "must contain at least one letter and one number"}},
+		{name: "password too short", change: func(s *signup) { s.Password = "a1" }, want: Errors{"password": "must be at least 8 characters"}},
+		{name: "whitespace only name", change: func(s *signup) { s.Name = " \t\n " }, want: Errors{"name": "cannot be blank"}},
+		{name: "no name", change: func(s *signup) { s.Name = "" }},
+		{name: "everything missing", change: func(s *signup) { *s = signup{} }, want: Errors{"username": "is required", "password": "is required"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := valid
+			test.change(&value)
+
+			err := Struct(&value)
+			if test.want == nil {
+				if err != nil {
+					t.Fatalf("error = %v, want none", err)
+				}
+				return
+			}
+
+			fields, ok := FromError(err)
+			if !ok {
+				t.Fatalf("error = %v, want field errors", err)
+			}
+			if len(fields) != len(test.want) {
+				t.Errorf("fields = %v, want %v", fields, test.want)
+			}
+			for field, message := range test.want {
+				if fields[field] != message {
+					t.Errorf("%s: %q, want %q", field, fields[field], message)
+				}
+			}
+		})
+	}
+}
+
+func TestVarUsesCustomRules(t *testing.T) {
+	if err := Var("root", "notreserved"); err == nil {
+		t.Error("reserved name passed notreserved")
+	}
+	if err := Var("   ", "notblank"); err == nil {
+		t.Error("whitespace passed notblank")
+	}
+	if _, ok := FromError(errors.New("not a validation error")); ok {
+		t.Error("a plain error was read as field errors")
+	}
+}
@@ -0,0 +1,69 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+const defaultAlbumPageSize = 20
+
+func BrowseArtist(ctx context.Context, event Event, c *Client) error {
+	var browseEvent BrowseArtistEvent
+	if ok, err := readPayload(c, event, &browseEvent); !ok {
+		return err
+	}
+
+	room := c.Manager.Rooms[c.RoomID]
+	browser, ok := services.BrowserFor(room.MusicProvider)
+	if !ok {
+		return sendError(c, "This room's music provider does not support browsing.")
+	}
+
+	songs, err := browser.ArtistTopTracks(ctx, browseEvent.ArtistId, room.HostID)
+	if err != nil {
+		return browseFailed(c, room, err)
+	}
+
+	page := models.SongPage{Songs: songs, Limit: len(songs), Total: len(songs)}
+	return sendBrowseResults(c, BrowseResultsEvent{SongPage: page, Kind: "artist", Id: browseEvent.ArtistId})
+}
+
+func BrowseAlbum(ctx context.Context, event Event, c *Client) error {
+	var browseEvent BrowseAlbumEvent
+	if ok, err := readPayload(c, event, &browseEvent); !ok {
+		return err
+	}
+	if browseEvent.Limit == 0 {
+		browseEvent.Limit = defaultAlbumPageSize
+	}
+
+	room := c.Manager.Rooms[c.RoomID]
+	browser, ok := services.BrowserFor(room.MusicProvider)
+	if !ok {
+		return sendError(c, "This room's music provider does not support browsing.")
+	}
+
+	page, err := browser.AlbumTracks(ctx, browseEvent.AlbumId, browseEvent.Offset, browseEvent.Limit, room.HostID)
+	if err != nil {
+		return browseFailed(c, room, err)
+	}
+
+	return sendBrowseResults(c, BrowseResultsEvent{SongPage: *page, Kind: "album", Id: browseEvent.AlbumId})
+}
+
+func browseFailed(c *Client, room *RoomData, err error) error {
+	return providerFailed(c, room, err, "browsing", "Could not load those songs.")
+}
+
+func sendBrowseResults(c *Client, results BrowseResultsEvent) error {
+	payload, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+
+	c.Egress <- Event{Type: BrowseResults, Payload: payload}
+	return nil
+}
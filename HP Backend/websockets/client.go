@@ -1,10 +1,13 @@
 package websockets
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"houseparty.com/models"
 )
@@ -14,11 +17,27 @@ type PlayList []models.Song
 type SkipRecord []int64
 
 type Client struct {
+	ID         string
+	SessionID  string
 	User       *models.User
 	Connection *websocket.Conn
 	RoomID     string
 	Manager    *Manager
 	Egress     chan Event
+	Logger     *slog.Logger
+	// Preview and wantsPreview are set by the client's read loop and read
+	// by the room's playback goroutine.
+	Preview atomic.Bool
+
+	wantsPreview atomic.Bool
+	// spotifyConnected starts out as the account's User.SpotifyConnected and
+	// is cleared when Spotify revokes the user's token, while the room's
+	// goroutines read it.
+	spotifyConnected atomic.Bool
+	// left ends when the client is removed, stopping work that runs beside
+	// its read loop.
+	left  context.Context
+	leave context.CancelFunc
 }
 
 var (
@@ -26,18 +45,71 @@ var (
 	pingInterval = (pongWait * 9) / 10
 )
 
-func NewClient(connection *websocket.Conn, manager *Manager, RoomID string, userId int64) *Client {
+const egressBufferSize = 16
+
+// maxMessageSize fits the largest event, an add-song with a 500 character
+// link and song id, even when every character arrives JSON escaped.
+const maxMessageSize = 8 << 10
+
+func NewClient(connection *websocket.Conn, manager *Manager, RoomID string, userId int64, sessionId string, logger *slog.Logger) *Client {
 	var user models.User
-	user.GetUserById(userId)
+	err := user.GetUserById(userId)
 
-	log.Println("USERNAME IS HERE :", user.Username)
+	connectionID := uuid.New().String()
+	logger = logger.With("conn_id", connectionID, "room_id", RoomID, "user_id", userId)
+	if err != nil {
+		logger.Warn("could not load user for connection", "error", err)
+	}
+	logger.Info("client connected", "username", user.Username)
 
-	return &Client{
+	left, leave := context.WithCancel(context.Background())
+	client := &Client{
+		ID:         connectionID,
+		SessionID:  sessionId,
+		Logger:     logger,
 		User:       &user,
 		Connection: connection,
 		RoomID:     RoomID,
 		Manager:    manager,
-		Egress:     make(chan Event),
+		Egress:     make(chan Event, egressBufferSize),
+		left:       left,
+		leave:      leave,
+	}
+	client.spotifyConnected.Store(user.SpotifyConnected)
+	return client
+}
+
+// background returns a context for work a handler leaves running, such as
+// expanding a pasted playlist. It keeps ctx's values and ends when the
+// client leaves.
+func (c *Client) background(ctx context.Context) (context.Context, context.CancelFunc) {
+	background, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(c.left, cancel)
+	return background, func() {
+		stop()
+		cancel()
+	}
+}
+
+// send queues event, giving up if the client leaves first so nothing waits
+// on a connection that is gone.
+func (c *Client) send(event Event) bool {
+	select {
+	case c.Egress <- event:
+		return true
+	case <-c.left.Done():
+		return false
+	}
+}
+
+// trySend queues event without waiting. A client whose buffer is full is too
+// far behind to hold up the sender, so it misses the event.
+func (c *Client) trySend(event Event) bool {
+	select {
+	case c.Egress <- event:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -51,11 +123,11 @@ func (c *Client) ReadMessages() {
 
 	err := c.Connection.SetReadDeadline(time.Now().Add(pongWait))
 	if err != nil {
-		log.Println("failed to set read deadline: ", err.Error())
+		c.Logger.Error("failed to set read deadline", "error", err)
 		return
 	}
 
-	c.Connection.SetReadLimit(512)
+	c.Connection.SetReadLimit(maxMessageSize)
 	c.Connection.SetPongHandler(c.PongHnadler)
 
 	for {
@@ -63,19 +135,19 @@ func (c *Client) ReadMessages() {
 
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Println(err.Error())
+				c.Logger.Warn("connection closed unexpectedly", "error", err)
 			}
 			break
 		}
 
 		var request Event
 		if err := json.Unmarshal(payLoad, &request); err != nil {
-			log.Println("failed to unmarshal message: ", err.Error())
+			c.Logger.Warn("failed to unmarshal message", "error", err)
 			break
 		}
 
 		if err := c.Manager.routeEvent(request, c); err != nil {
-			log.Println("failed to route event: ", err.Error())
+			c.Logger.Error("failed to route event", "type", request.Type, "error", err)
 			break
 		}
 
@@ -98,24 +170,24 @@ func (c *Client) WriteMessages() {
 
 			if !ok {
 				if err := c.Connection.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
-					log.Println("conncetion closed: ", err.Error())
+					c.Logger.Debug("connection closed", "error", err)
 				}
 				return
 			}
 
 			data, err := json.Marshal(message)
 			if err != nil {
-				log.Println("failed to marshal message: ", err.Error())
+				c.Logger.Error("failed to marshal message", "type", message.Type, "error", err)
 				return
 			}
 
 			if err := c.Connection.WriteMessage(websocket.TextMessage, data); err != nil {
-				log.Println("failed to send message: ", err.Error())
+				c.Logger.Warn("failed to send message", "type", message.Type, "error", err)
 			}
 
 		case <-ticker.C:
 			if err := c.Connection.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
-				log.Println("failed to send ping: ", err.Error())
+				c.Logger.Debug("failed to send ping", "error", err)
 				return
 			}
 		}
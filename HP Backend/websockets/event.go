@@ -1,28 +1,53 @@
 package websockets
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
 	"slices"
 	"time"
 
-	"houseparty.com/config"
 	"houseparty.com/models"
 	"houseparty.com/services"
+	"houseparty.com/validation"
 )
 
 // Define Event Types
 const (
-	EventJoinRoom     = "joined-room"
-	EventSearchSongs  = "search-songs"
-	EventAddSong      = "add-song"
-	EventPlaySong     = "play-song"
-	AddedSongPlaylist = "added-song-playlist"
-	SetAndPlaySong    = "set-and-play-song"
-	EventSkipRequest  = "skip-request"
-	EventSongSkipped  = "song-skipped"
-	FinalSongEnded    = "final-song-ended"
-	UserLeft          = "user-left"
+	EventJoinRoom       = "joined-room"
+	EventSearchSongs    = "search-songs"
+	EventAddSong        = "add-song"
+	EventPlaySong       = "play-song"
+	AddedSongPlaylist   = "added-song-playlist"
+	SetAndPlaySong      = "set-and-play-song"
+	EventSkipRequest    = "skip-request"
+	EventSongSkipped    = "song-skipped"
+	FinalSongEnded      = "final-song-ended"
+	UserLeft            = "user-left"
+	SearchUnavailable   = "search-unavailable"
+	SpotifyDisconnected = "spotify-disconnected"
+	ListDevicesRequest  = "list-devices"
+	DevicesList         = "devices"
+	SelectDeviceRequest = "select-device"
+	PlaybackPaused      = "playback-paused"
+	PlaybackResumed     = "playback-resumed"
+	PlaybackFailed      = "playback-failed"
+	SpeakerLost         = "speaker-lost"
+	EventError          = "error"
+	EventBrowseArtist   = "browse-artist"
+	EventBrowseAlbum    = "browse-album"
+	BrowseResults       = "browse-results"
+	EventImportSongs    = "import-songs"
+	ImportProgress      = "import-progress"
+	ImportSkipped       = "import-skipped"
+	ImportFinished      = "import-finished"
+	AddedSongsPlaylist  = "added-songs-playlist"
+	ConfirmSongsOffer   = "add-songs-confirmation"
+	EventConfirmSongs   = "confirm-add-songs"
+	AddSongsResult      = "add-songs-result"
+	EventSetPreview     = "set-preview-mode"
+	PreviewMode         = "preview-mode"
+	PreviewListeners    = "preview-listeners"
 )
 
 // Define Event Struct and Event Handler
@@ -30,7 +55,22 @@ type Event struct {
 	Type    string          `json:"type"`
 	Payload json.RawMessage `json:"payload"`
 }
-type EventHandler func(event Event, c *Client) error
+type EventHandler func(ctx context.Context, event Event, c *Client) error
+
+// readPayload decodes an event payload and checks its binding rules. A
+// payload that breaks a rule is answered with an error event listing the
+// fields, and the connection stays open.
+func readPayload(c *Client, event Event, payload any) (bool, error) {
+	if err := json.Unmarshal(event.Payload, payload); err != nil {
+		return false, err
+	}
+
+	err := validation.Struct(payload)
+	if fields, ok := validation.FromError(err); ok {
+		return false, sendInvalid(c, fields)
+	}
+	return err == nil, err
+}
 
 // Define Event Payloads
 type JoinedRoomEvent struct {
@@ -40,28 +80,45 @@ type JoinedRoomEvent struct {
 	ApiToken     string        `json:"api_token"`
 	SongPosition int64         `json:"song_position"`
 	HostID       int64         `json:"host_id"`
+	PlaybackMode string        `json:"playback_mode"`
+	PreviewClips bool          `json:"preview_clips"`
+	Preview      bool          `json:"preview"`
 }
 type SongChangeEvent struct {
 	PlayList    []models.Song `json:"playlist"`
 	CurrentSong *models.Song  `json:"current_song"`
-	ApiToken    string        `json:"api_token"`
 }
 
 type SearchSongsEvent struct {
-	Search string `json:"search"`
+	services.SearchQuery
 }
 type SearchResultsEvent struct {
-	Songs []models.Song `json:"songs"`
+	models.SongPage
+	Query services.SearchQuery `json:"query"`
+}
+
+type BrowseArtistEvent struct {
+	ArtistId string `json:"artist_id" binding:"required,max=100"`
+}
+type BrowseAlbumEvent struct {
+	AlbumId string `json:"album_id" binding:"required,max=100"`
+	Offset  int    `json:"offset" binding:"min=0"`
+	Limit   int    `json:"limit" binding:"omitempty,min=1,max=50"`
+}
+type BrowseResultsEvent struct {
+	models.SongPage
+	Kind string `json:"kind"`
+	Id   string `json:"id"`
 }
 
 type AddSongEvent struct {
-	From   string `json:"from"`
-	SongId string `json:"song_id"`
+	From   string `json:"from" binding:"max=64"`
+	SongId string `json:"song_id" binding:"required_without=Link,max=500"`
+	Link   string `json:"link" binding:"max=500"`
 }
 type AddSongResultEvent struct {
-	From     string       `json:"from"`
-	Song     *models.Song `json:"song"`
-	ApiToken string       `json:"api_token"`
+	From string       `json:"from"`
+	Song *models.Song `json:"song"`
 }
 
 type AddedSongToPlaylist struct {
@@ -69,13 +126,18 @@ type AddedSongToPlaylist struct {
 	Song *models.Song `json:"song"`
 }
 
+type SearchUnavailableEvent struct {
+	Message string `json:"message"`
+}
+
 type SetAndPlayCurrentSong struct {
 	ApiToken string       `json:"api_token"`
 	Song     *models.Song `json:"song"`
+	Preview  bool         `json:"preview"`
 }
 
 // Define Event Handlers
-func JoinRoom(event Event, c *Client) error {
+func JoinRoom(ctx context.Context, event Event, c *Client) error {
 	room := c.Manager.Rooms[c.RoomID]
 
 	for client := range room.Clients {
@@ -85,19 +147,27 @@ func JoinRoom(event Event, c *Client) error {
 		client.Egress <- event
 	}
 
-	apiToken, err := config.GetSpotifyTokenObject(room.HostID)
-	if err != nil {
-		return err
-	}
+	room.queueLock.Lock()
+	playList := slices.Clone(room.PlayList)
+	currentSong := room.CurrentSong
 	songPosition := time.Since(room.CurrentSongStartedAt)
+	room.queueLock.Unlock()
+
+	apiToken, preview, changed := room.playbackFor(ctx, c, currentSong)
+	if changed {
+		room.sendPreviewListeners()
+	}
 
 	joinedEvent := JoinedRoomEvent{
 		UserCount:    len(room.Clients),
-		PlayList:     room.PlayList,
-		CurrentSong:  room.CurrentSong,
-		ApiToken:     apiToken.AccessToken,
+		PlayList:     playList,
+		CurrentSong:  currentSong,
+		ApiToken:     apiToken,
 		SongPosition: songPosition.Milliseconds(),
 		HostID:       room.HostID,
+		PlaybackMode: room.PlaybackMode,
+		PreviewClips: room.PreviewClips,
+		Preview:      preview,
 	}
 
 	joinedPayload, err := json.Marshal(joinedEvent)
@@ -113,27 +183,23 @@ func JoinRoom(event Event, c *Client) error {
 	return nil
 }
 
-func SearchSongs(event Event, c *Client) error {
+func SearchSongs(ctx context.Context, event Event, c *Client) error {
 	var searchEvent SearchSongsEvent
-	err := json.Unmarshal(event.Payload, &searchEvent)
 	room := c.Manager.Rooms[c.RoomID]
 
-	if err != nil {
-		log.Println(err)
+	if ok, err := readPayload(c, event, &searchEvent); !ok {
 		return err
 	}
-	tracks, err := services.SearchSongs(searchEvent.Search, room.HostID)
-	if err != nil {
-		log.Println(err)
-		return err
+	if err := searchEvent.Normalize(); err != nil {
+		return sendError(c, err.Error())
 	}
-	songs, err := services.SimplifyTracks(tracks)
+
+	page, err := room.MusicProvider.SearchSongs(ctx, searchEvent.SearchQuery, room.HostID)
 	if err != nil {
-		log.Println(err)
-		return err
+		return providerFailed(c, room, err, "song search", "Could not search for songs.", "host_id", room.HostID)
 	}
 
-	responsePayload, err := json.Marshal(SearchResultsEvent{Songs: songs})
+	responsePayload, err := json.Marshal(SearchResultsEvent{SongPage: *page, Query: searchEvent.SearchQuery})
 	if err != nil {
 		return err
 	}
@@ -147,29 +213,42 @@ func SearchSongs(event Event, c *Client) error {
 	return nil
 }
 
-func AddSong(event Event, c *Client) error {
+func AddSong(ctx context.Context, event Event, c *Client) error {
 	var addSongEvent AddSongEvent
 	var response Event
 	room := c.Manager.Rooms[c.RoomID]
 
-	err := json.Unmarshal(event.Payload, &addSongEvent)
-	if err != nil {
+	if ok, err := readPayload(c, event, &addSongEvent); !ok {
 		return err
 	}
 
-	song, err := services.GetSongById(addSongEvent.SongId, room.HostID)
-	if err != nil {
-		return err
+	var err error
+	songId := addSongEvent.SongId
+	link := addSongEvent.Link
+	if link == "" && services.LooksLikeLink(songId) {
+		link = songId
 	}
-
-	if room.CurrentSong == nil {
-		err := room.PrepareSongToPlay(song)
-		if err != nil {
+	if link != "" {
+		songId, err = room.resolveSongLink(ctx, c, link, addSongEvent.From)
+		if songId == "" {
 			return err
 		}
+	}
+
+	song, err := room.MusicProvider.GetSongById(ctx, songId, room.HostID)
+	if err != nil {
+		return providerFailed(c, room, err, "song lookup", "Could not add that song.", "song_id", songId)
+	}
+
+	reason, start := room.queueSong(song)
+	if reason != "" {
+		return sendError(c, song.Name+" was not added: "+reason+".")
+	}
 
+	if start {
+		go room.PlaySong(context.WithoutCancel(ctx), song)
 	} else {
-		payload, err := room.AddSongToPlaylist(song, addSongEvent.From)
+		payload, err := json.Marshal(AddedSongToPlaylist{From: addSongEvent.From, Song: song})
 		if err != nil {
 			return err
 		}
@@ -181,7 +260,31 @@ func AddSong(event Event, c *Client) error {
 	return nil
 }
 
-func SkipSongRequest(event Event, c *Client) error {
+// providerFailed reports a music provider error to the client. Spotify
+// trouble is no reason to drop the connection, so only a failure to send the
+// report is returned.
+func providerFailed(c *Client, room *RoomData, err error, operation, message string, attrs ...any) error {
+	attrs = append(attrs, "provider", room.MusicProvider.Name(), "error", err)
+	if errors.Is(err, services.ErrProviderUnavailable) {
+		c.Logger.Warn(operation+" unavailable", attrs...)
+		return sendSearchUnavailable(c)
+	}
+
+	c.Logger.Warn(operation+" failed", attrs...)
+	return sendError(c, message)
+}
+
+func sendSearchUnavailable(c *Client) error {
+	payload, err := json.Marshal(SearchUnavailableEvent{Message: "Search is temporarily unavailable, please try again shortly."})
+	if err != nil {
+		return err
+	}
+
+	c.send(Event{Type: SearchUnavailable, Payload: payload})
+	return nil
+}
+
+func SkipSongRequest(ctx context.Context, event Event, c *Client) error {
 	room := c.Manager.Rooms[c.RoomID]
 	if slices.Contains(room.UserSkipRecord[:], c.User.Id) {
 		return nil
@@ -204,7 +307,7 @@ func SkipSongRequest(event Event, c *Client) error {
 	return nil
 }
 
-func HandleUserLeaving(event Event, c *Client) error {
+func HandleUserLeaving(ctx context.Context, event Event, c *Client) error {
 	room := c.Manager.Rooms[c.RoomID]
 	room.UserLeftRoom()
 	return nil
@@ -0,0 +1,94 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+// failingProvider fails every catalogue lookup with err.
+type failingProvider struct {
+	*services.FakeProvider
+	err error
+}
+
+func (p *failingProvider) SearchSongs(ctx context.Context, query services.SearchQuery, userId int64) (*models.SongPage, error) {
+	return nil, p.err
+}
+
+func (p *failingProvider) GetSongById(ctx context.Context, id string, userId int64) (*models.Song, error) {
+	return nil, p.err
+}
+
+func newTestManager(room *RoomData) *Manager {
+	manager := &Manager{Rooms: make(RoomDataList), Handlers: make(map[string]EventHandler)}
+	manager.Rooms[room.ID] = room
+	return manager
+}
+
+func testEvent(t *testing.T, eventType string, payload any) Event {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Event{Type: eventType, Payload: data}
+}
+
+func TestProviderErrorsKeepTheClientConnected(t *testing.T) {
+	providerErrors := map[string]error{
+		"unavailable":  fmt.Errorf("%w: open breaker", services.ErrProviderUnavailable),
+		"unauthorized": errors.New("spotify: 401 invalid access token"),
+		"rate limited": errors.New("spotify: 429 too many requests"),
+		"bad response": errors.New("invalid character '<' looking for beginning of value"),
+	}
+	handlers := map[string]struct {
+		handler EventHandler
+		event   Event
+	}{
+		EventSearchSongs: {handler: SearchSongs, event: testEvent(t, EventSearchSongs, map[string]any{"search": "anthem"})},
+		EventAddSong:     {handler: AddSong, event: testEvent(t, EventAddSong, AddSongEvent{SongId: "fake-1"})},
+	}
+
+	for name, providerErr := range providerErrors {
+		for eventType, test := range handlers {
+			t.Run(name+"/"+eventType, func(t *testing.T) {
+				room := newTestRoom()
+				room.MusicProvider = &failingProvider{FakeProvider: services.NewFakeProvider(), err: providerErr}
+				client := newTestClient(room, 2, 1)
+				client.Manager = newTestManager(room)
+
+				if err := test.handler(context.Background(), test.event, client); err != nil {
+					t.Fatalf("handler returned %v, which would close the socket", err)
+				}
+				if len(client.Egress) != 1 {
+					t.Fatal("client was not told about the failure")
+				}
+				event := <-client.Egress
+				want := EventError
+				if errors.Is(providerErr, services.ErrProviderUnavailable) {
+					want = SearchUnavailable
+				}
+				if event.Type != want {
+					t.Errorf("event = %s, want %s", event.Type, want)
+				}
+			})
+		}
+	}
+}
+
+func TestMalformedPayloadIsStillAnError(t *testing.T) {
+	room := newTestRoom()
+	room.MusicProvider = services.NewFakeProvider()
+	client := newTestClient(room, 2, 1)
+	client.Manager = newTestManager(room)
+
+	if err := SearchSongs(context.Background(), Event{Type: EventSearchSongs, Payload: []byte("{")}, client); err == nil {
+		t.Error("a payload that is not JSON was accepted")
+	}
+}
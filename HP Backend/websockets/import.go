@@ -0,0 +1,189 @@
+package websockets
+
+import (
+	"context"
+	"errors"
+
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+var (
+	ErrRoomNotActive    = errors.New("room has no active session")
+	ErrNotRoomHost      = errors.New("only the host can do this")
+	ErrImportInProgress = errors.New("an import is already running in this room")
+)
+
+type ImportSongsEvent struct {
+	From string `json:"from" binding:"max=64"`
+	Link string `json:"link" binding:"required,max=500"`
+}
+
+type SkippedSong struct {
+	Song   *models.Song `json:"song,omitempty"`
+	Reason string       `json:"reason"`
+}
+
+type ImportProgressEvent struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+	Total    int `json:"total"`
+}
+
+type ImportSkippedEvent struct {
+	Skipped []SkippedSong `json:"skipped"`
+}
+
+type ImportResult struct {
+	Imported int           `json:"imported"`
+	Total    int           `json:"total"`
+	Skipped  []SkippedSong `json:"skipped"`
+}
+
+type AddedSongsToPlaylist struct {
+	From  string        `json:"from"`
+	Songs []models.Song `json:"songs"`
+}
+
+func ImportSongs(ctx context.Context, event Event, c *Client) error {
+	var importEvent ImportSongsEvent
+	if ok, err := readPayload(c, event, &importEvent); !ok {
+		return err
+	}
+
+	room := c.Manager.Rooms[c.RoomID]
+	if c.User.Id != room.HostID {
+		return sendError(c, "Only the host can import songs.")
+	}
+
+	// Fetching a long playlist takes a while, so it runs beside the read
+	// loop to keep pongs and the host's other messages flowing.
+	go importForClient(context.WithoutCancel(ctx), room, c, importEvent.Link)
+	return nil
+}
+
+func importForClient(ctx context.Context, room *RoomData, c *Client, link string) {
+	result, err := room.ImportSongs(ctx, link, c.User.Username)
+	switch {
+	case errors.Is(err, services.ErrUnsupportedLink):
+		err = sendError(c, "That link is not a playlist, album or track this room can import.")
+	case errors.Is(err, services.ErrProviderUnavailable):
+		c.Logger.Warn("song import unavailable", "provider", room.MusicProvider.Name(), "error", err)
+		err = sendSearchUnavailable(c)
+	case err != nil && result == nil:
+		err = sendError(c, err.Error())
+	case err != nil:
+		c.Logger.Warn("song import stopped early", "imported", result.Imported, "error", err)
+		err = nil
+	}
+	if err != nil {
+		c.Logger.Error("could not report song import", "error", err)
+	}
+}
+
+// ImportSongs imports a link into an active room on behalf of its host.
+func (m *Manager) ImportSongs(ctx context.Context, roomID string, userId int64, from, link string) (*ImportResult, error) {
+	m.RLock()
+	room, ok := m.Rooms[roomID]
+	m.RUnlock()
+
+	if !ok {
+		return nil, ErrRoomNotActive
+	}
+	if room.HostID != userId {
+		return nil, ErrNotRoomHost
+	}
+	return room.ImportSongs(ctx, link, from)
+}
+
+// ImportSongs queues every song behind link that passes the room's content
+// rules. Progress and skipped songs are reported to the host page by page, and
+// queued songs are broadcast as they arrive. When an import fails part way the
+// songs already queued stay queued and the partial result is returned with
+// the error.
+func (r *RoomData) ImportSongs(ctx context.Context, link, from string) (*ImportResult, error) {
+	importer, ok := services.TrackImporterFor(r.MusicProvider)
+	if !ok {
+		return nil, services.ErrUnsupportedLink
+	}
+
+	r.importLock.Lock()
+	if r.importing {
+		r.importLock.Unlock()
+		return nil, ErrImportInProgress
+	}
+	r.importing = true
+	r.importLock.Unlock()
+
+	defer func() {
+		r.importLock.Lock()
+		r.importing = false
+		r.importLock.Unlock()
+	}()
+
+	r.Logger.Info("importing songs", "link", link)
+	result := &ImportResult{Skipped: []SkippedSong{}}
+
+	err := importer.ImportTracks(ctx, link, r.HostID, func(page services.ImportPage) error {
+		result.Total = page.Total
+
+		var skipped []SkippedSong
+		for i := 0; i < page.Unavailable; i++ {
+			skipped = append(skipped, SkippedSong{Reason: SkipUnavailable})
+		}
+
+		queued, rejected := r.queueSongs(ctx, page.Songs, from)
+		result.Imported += queued
+		skipped = append(skipped, rejected...)
+		result.Skipped = append(result.Skipped, skipped...)
+
+		if len(skipped) > 0 {
+			r.sendToHost(ImportSkipped, ImportSkippedEvent{Skipped: skipped})
+		}
+		r.sendToHost(ImportProgress, ImportProgressEvent{
+			Imported: result.Imported,
+			Skipped:  len(result.Skipped),
+			Total:    result.Total,
+		})
+		return nil
+	})
+
+	if err != nil && result.Imported == 0 && len(result.Skipped) == 0 {
+		return nil, err
+	}
+
+	r.Logger.Info("songs imported", "imported", result.Imported, "skipped", len(result.Skipped), "total", result.Total)
+	r.sendToHost(ImportFinished, result)
+	return result, err
+}
+
+// queueSongs queues the songs that pass the room's content rules, starting
+// playback with the first one when nothing is playing, and broadcasts the
+// rest as one batch. It returns how many were queued and which were skipped.
+func (r *RoomData) queueSongs(ctx context.Context, songs []models.Song, from string) (int, []SkippedSong) {
+	var added []models.Song
+	var skipped []SkippedSong
+	queued := 0
+
+	for i := range songs {
+		song := songs[i]
+		reason, start := r.queueSong(&song)
+		if reason != "" {
+			skipped = append(skipped, SkippedSong{Song: &song, Reason: reason})
+			continue
+		}
+
+		queued++
+		if start {
+			// The song keeps playing after the request that queued it.
+			go r.PlaySong(context.WithoutCancel(ctx), &song)
+			continue
+		}
+		added = append(added, song)
+	}
+
+	if len(added) > 0 {
+		r.sendToAll(AddedSongsPlaylist, AddedSongsToPlaylist{From: from, Songs: added})
+	}
+	return queued, skipped
+}
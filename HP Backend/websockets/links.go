@@ -0,0 +1,173 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+const (
+	maxLinkSongs        = 100
+	confirmationTimeout = 5 * time.Minute
+)
+
+var errLinkSongsFull = errors.New("link expansion is full")
+
+type pendingSongs struct {
+	ID        string
+	From      string
+	Songs     []models.Song
+	Total     int
+	ExpiresAt time.Time
+}
+
+type ConfirmSongsOfferEvent struct {
+	ConfirmationID string                `json:"confirmation_id"`
+	Link           services.ResolvedLink `json:"link"`
+	Songs          []models.Song         `json:"songs"`
+	Total          int                   `json:"total"`
+	Unavailable    int                   `json:"unavailable"`
+}
+
+type ConfirmAddSongsEvent struct {
+	ConfirmationID string `json:"confirmation_id" binding:"required"`
+	Confirm        bool   `json:"confirm"`
+}
+
+// resolveSongLink turns a pasted link into a track id. Album and playlist
+// links are not queued straight away: their songs are sent back to the
+// client to confirm, and the returned id is empty.
+func (r *RoomData) resolveSongLink(ctx context.Context, c *Client, link, from string) (string, error) {
+	resolved, err := services.ResolveLink(ctx, r.MusicProvider, link)
+	switch {
+	case errors.Is(err, services.ErrUnsupportedLink):
+		return "", sendError(c, "That link is not a song, album or playlist this room can play.")
+	case errors.Is(err, services.ErrProviderUnavailable):
+		return "", sendSearchUnavailable(c)
+	case err != nil:
+		c.Logger.Warn("could not resolve song link", "error", err)
+		return "", sendError(c, "Could not open that link.")
+	}
+
+	if resolved.Kind == services.LinkTrack {
+		return resolved.ID, nil
+	}
+
+	// Expanding an album or playlist takes several provider calls, so it
+	// runs beside the read loop and the offer follows when it is ready.
+	background, cancel := c.background(ctx)
+	go func() {
+		defer cancel()
+		if err := r.offerLinkSongs(background, c, resolved, from); err != nil {
+			c.Logger.Error("could not offer linked songs", "error", err)
+		}
+	}()
+	return "", nil
+}
+
+func (r *RoomData) offerLinkSongs(ctx context.Context, c *Client, link *services.ResolvedLink, from string) error {
+	importer, ok := services.TrackImporterFor(r.MusicProvider)
+	if !ok {
+		return sendError(c, "This room cannot add albums or playlists.")
+	}
+
+	pending := &pendingSongs{
+		ID:        uuid.New().String(),
+		From:      from,
+		ExpiresAt: time.Now().Add(confirmationTimeout),
+	}
+	unavailable := 0
+
+	err := importer.ImportTracks(ctx, link.Link, r.HostID, func(page services.ImportPage) error {
+		pending.Total = page.Total
+		unavailable += page.Unavailable
+		pending.Songs = append(pending.Songs, page.Songs...)
+		if len(pending.Songs) >= maxLinkSongs {
+			pending.Songs = pending.Songs[:maxLinkSongs]
+			return errLinkSongsFull
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errLinkSongsFull):
+	case errors.Is(err, services.ErrProviderUnavailable):
+		return sendSearchUnavailable(c)
+	case err != nil:
+		c.Logger.Warn("could not load linked songs", "kind", link.Kind, "id", link.ID, "error", err)
+		return sendError(c, "Could not load the songs behind that link.")
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	if len(pending.Songs) == 0 {
+		return sendError(c, "There are no playable songs behind that link.")
+	}
+
+	r.pendingLock.Lock()
+	if c.left.Err() == nil {
+		r.pendingSongs[c.ID] = pending
+	}
+	r.pendingLock.Unlock()
+
+	payload, err := json.Marshal(ConfirmSongsOfferEvent{
+		ConfirmationID: pending.ID,
+		Link:           *link,
+		Songs:          pending.Songs,
+		Total:          pending.Total,
+		Unavailable:    unavailable,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.send(Event{Type: ConfirmSongsOffer, Payload: payload})
+	return nil
+}
+
+// ConfirmAddSongs queues, or drops, the songs a client was offered after
+// pasting an album or playlist link. Each client has at most one offer open.
+func ConfirmAddSongs(ctx context.Context, event Event, c *Client) error {
+	var confirmEvent ConfirmAddSongsEvent
+	if ok, err := readPayload(c, event, &confirmEvent); !ok {
+		return err
+	}
+
+	room := c.Manager.Rooms[c.RoomID]
+	room.pendingLock.Lock()
+	pending, ok := room.pendingSongs[c.ID]
+	if ok && pending.ID == confirmEvent.ConfirmationID {
+		delete(room.pendingSongs, c.ID)
+	}
+	room.pendingLock.Unlock()
+
+	if !ok || pending.ID != confirmEvent.ConfirmationID || time.Now().After(pending.ExpiresAt) {
+		return sendError(c, "That request has expired, please paste the link again.")
+	}
+	if !confirmEvent.Confirm {
+		return nil
+	}
+
+	queued, skipped := room.queueSongs(ctx, pending.Songs, pending.From)
+	if skipped == nil {
+		skipped = []SkippedSong{}
+	}
+	payload, err := json.Marshal(ImportResult{Imported: queued, Total: len(pending.Songs), Skipped: skipped})
+	if err != nil {
+		return err
+	}
+
+	c.Egress <- Event{Type: AddSongsResult, Payload: payload}
+	return nil
+}
+
+func (r *RoomData) dropPendingSongs(c *Client) {
+	r.pendingLock.Lock()
+	delete(r.pendingSongs, c.ID)
+	r.pendingLock.Unlock()
+}
@@ -0,0 +1,147 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+func newLinkTestRoom() *RoomData {
+	room := newTestRoom()
+	room.MusicProvider = services.NewFakeProvider(
+		models.Song{Id: "fake-5", Name: "Green Build", Album: "Pipelines", DurationMs: 195000},
+		models.Song{Id: "fake-6", Name: "Race Condition", Album: "Pipelines", DurationMs: 142000},
+	)
+	// Something is already playing, so confirmed songs are only queued.
+	room.CurrentSong = &models.Song{Id: "playing"}
+	return room
+}
+
+// nextEvent waits for the next event queued for client.
+func nextEvent(t *testing.T, client *Client) Event {
+	t.Helper()
+	select {
+	case event := <-client.Egress:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("client got no event")
+		return Event{}
+	}
+}
+
+func offerAlbum(t *testing.T, room *RoomData, client *Client) ConfirmSongsOfferEvent {
+	t.Helper()
+	event := testEvent(t, EventAddSong, AddSongEvent{Link: "fake:album:Pipelines", From: "erin"})
+	if err := AddSong(context.Background(), event, client); err != nil {
+		t.Fatal(err)
+	}
+
+	offer := nextEvent(t, client)
+	if offer.Type != ConfirmSongsOffer {
+		t.Fatalf("event = %s, want %s", offer.Type, ConfirmSongsOffer)
+	}
+	var payload ConfirmSongsOfferEvent
+	if err := json.Unmarshal(offer.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.Songs) == 0 {
+		t.Fatal("offer has no songs")
+	}
+	return payload
+}
+
+func TestConfirmAddSongsQueuesOfferedSongs(t *testing.T) {
+	room := newLinkTestRoom()
+	client := newTestClient(room, 2, 4)
+	client.Manager = newTestManager(room)
+	offer := offerAlbum(t, room, client)
+
+	event := testEvent(t, EventConfirmSongs, ConfirmAddSongsEvent{ConfirmationID: offer.ConfirmationID, Confirm: true})
+	if err := ConfirmAddSongs(context.Background(), event, client); err != nil {
+		t.Fatal(err)
+	}
+	if added := nextEvent(t, client); added.Type != AddedSongsPlaylist {
+		t.Fatalf("event = %s, want %s", added.Type, AddedSongsPlaylist)
+	}
+	result := nextEvent(t, client)
+	var imported ImportResult
+	if err := json.Unmarshal(result.Payload, &imported); err != nil {
+		t.Fatal(err)
+	}
+	if result.Type != AddSongsResult || imported.Imported != len(offer.Songs) {
+		t.Errorf("result = %s %+v, want all %d songs queued", result.Type, imported, len(offer.Songs))
+	}
+	if len(room.PlayList) != len(offer.Songs) {
+		t.Errorf("playlist has %d songs, want %d", len(room.PlayList), len(offer.Songs))
+	}
+
+	// An offer can only be confirmed once.
+	if err := ConfirmAddSongs(context.Background(), event, client); err != nil {
+		t.Fatal(err)
+	}
+	if again := nextEvent(t, client); again.Type != EventError {
+		t.Errorf("confirming twice sent %s, want %s", again.Type, EventError)
+	}
+}
+
+func TestConfirmAddSongsRejectsStaleOffers(t *testing.T) {
+	tests := map[string]func(room *RoomData, client *Client, offer *ConfirmSongsOfferEvent){
+		"expired": func(room *RoomData, client *Client, offer *ConfirmSongsOfferEvent) {
+			room.pendingSongs[client.ID].ExpiresAt = time.Now().Add(-time.Second)
+		},
+		"another offer": func(room *RoomData, client *Client, offer *ConfirmSongsOfferEvent) {
+			offer.ConfirmationID = "not-the-offer"
+		},
+		"client rejoined": func(room *RoomData, client *Client, offer *ConfirmSongsOfferEvent) {
+			room.dropPendingSongs(client)
+		},
+	}
+
+	for name, spoil := range tests {
+		t.Run(name, func(t *testing.T) {
+			room := newLinkTestRoom()
+			client := newTestClient(room, 2, 4)
+			client.Manager = newTestManager(room)
+			offer := offerAlbum(t, room, client)
+			spoil(room, client, &offer)
+
+			event := testEvent(t, EventConfirmSongs, ConfirmAddSongsEvent{ConfirmationID: offer.ConfirmationID, Confirm: true})
+			if err := ConfirmAddSongs(context.Background(), event, client); err != nil {
+				t.Fatal(err)
+			}
+			if reply := nextEvent(t, client); reply.Type != EventError {
+				t.Errorf("event = %s, want %s", reply.Type, EventError)
+			}
+			if len(room.PlayList) != 0 {
+				t.Errorf("%d songs were queued from a stale offer", len(room.PlayList))
+			}
+		})
+	}
+}
+
+func TestLinkOfferIsDroppedWhenClientLeaves(t *testing.T) {
+	room := newLinkTestRoom()
+	client := newTestClient(room, 2, 0)
+	client.Manager = newTestManager(room)
+	client.leave()
+
+	resolved := &services.ResolvedLink{Kind: services.LinkAlbum, ID: "Pipelines", Link: "fake:album:Pipelines"}
+	done := make(chan error, 1)
+	go func() { done <- room.offerLinkSongs(context.Background(), client, resolved, "erin") }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("offer waited on a client that left")
+	}
+	if _, ok := room.pendingSongs[client.ID]; ok {
+		t.Error("offer was kept for a client that left")
+	}
+}
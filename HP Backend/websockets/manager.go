@@ -1,14 +1,21 @@
 package websockets
 
 import (
+	"context"
 	"errors"
-	"log"
 	"net/http"
 	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"houseparty.com/logging"
+	"houseparty.com/metrics"
 	"houseparty.com/models"
+	"houseparty.com/services"
+	"houseparty.com/tracing"
 )
 
 var (
@@ -31,14 +38,126 @@ func (m *Manager) CountClients(roomID string) int {
 	return len(m.Rooms[roomID].Clients)
 }
 
+func (m *Manager) EgressDepth() int {
+	m.RLock()
+	defer m.RUnlock()
+
+	depth := 0
+	for _, room := range m.Rooms {
+		for client := range room.Clients {
+			depth += len(client.Egress)
+		}
+	}
+	return depth
+}
+
+func (m *Manager) ActiveHostIDs() []int64 {
+	m.RLock()
+	defer m.RUnlock()
+
+	var hostIds []int64
+	for _, room := range m.Rooms {
+		if len(room.Clients) > 0 {
+			hostIds = append(hostIds, room.HostID)
+		}
+	}
+	return hostIds
+}
+
+// NotifySpotifyRevoked tells every room hosted by the user, and the user's
+// own connections, that their Spotify account is no longer connected. The
+// user's connections stop being handed Spotify tokens straight away.
+func (m *Manager) NotifySpotifyRevoked(userId int64) {
+	var targets []*Client
+	m.RLock()
+	for _, room := range m.Rooms {
+		for client := range room.Clients {
+			if client.User.Id == userId {
+				client.spotifyConnected.Store(false)
+			}
+			if room.HostID == userId || client.User.Id == userId {
+				targets = append(targets, client)
+			}
+		}
+	}
+	m.RUnlock()
+
+	event := Event{Type: SpotifyDisconnected, Payload: nil}
+	for _, client := range targets {
+		if !client.trySend(event) {
+			client.Logger.Warn("dropped event for slow client", "type", event.Type)
+		}
+	}
+}
+
+// CloseSession disconnects every connection opened with a session that has
+// just been logged out.
+func (m *Manager) CloseSession(sessionId string) {
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, room := range m.Rooms {
+		for client := range room.Clients {
+			if client.SessionID == sessionId {
+				client.Connection.Close()
+			}
+		}
+	}
+}
+
+// RemoveUser disconnects a deleted account: their own connections, and every
+// connection to the rooms they hosted, which are dropped.
+func (m *Manager) RemoveUser(userId int64, hostedRoomIds []string) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, roomId := range hostedRoomIds {
+		room, ok := m.Rooms[roomId]
+		if !ok {
+			continue
+		}
+		for client := range room.Clients {
+			client.leave()
+			client.Connection.Close()
+			metrics.WebsocketConnections.Dec()
+		}
+		delete(m.Rooms, roomId)
+	}
+	metrics.ActiveRooms.Set(float64(len(m.Rooms)))
+
+	for _, room := range m.Rooms {
+		for client := range room.Clients {
+			if client.User.Id == userId {
+				client.Connection.Close()
+			}
+		}
+	}
+}
+
 func (m *Manager) routeEvent(event Event, c *Client) error {
+	ctx, span := tracing.Tracer.Start(context.Background(), "websocket.event "+event.Type,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("event.type", event.Type),
+			attribute.String("room.id", c.RoomID),
+			attribute.String("connection.id", c.ID),
+			attribute.Int64("user.id", c.User.Id),
+		),
+	)
+	defer span.End()
+
 	if handler, ok := m.Handlers[event.Type]; ok {
-		if err := handler(event, c); err != nil {
+		if err := handler(ctx, event, c); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			metrics.EventsRouted.WithLabelValues(event.Type, "error").Inc()
 			return err
 		}
 	} else {
+		span.SetStatus(codes.Error, "no handler for event type")
+		metrics.EventsRouted.WithLabelValues("unknown", "unhandled").Inc()
 		return errors.New("no handler for event type")
 	}
+	metrics.EventsRouted.WithLabelValues(event.Type, "ok").Inc()
 	return nil
 }
 
@@ -58,6 +177,13 @@ func (m *Manager) SetupEventHandlers() {
 	m.Handlers[EventAddSong] = AddSong
 	m.Handlers[EventSkipRequest] = SkipSongRequest
 	m.Handlers[UserLeft] = HandleUserLeaving
+	m.Handlers[ListDevicesRequest] = ListDevices
+	m.Handlers[SelectDeviceRequest] = SelectDevice
+	m.Handlers[EventImportSongs] = ImportSongs
+	m.Handlers[EventBrowseArtist] = BrowseArtist
+	m.Handlers[EventBrowseAlbum] = BrowseAlbum
+	m.Handlers[EventConfirmSongs] = ConfirmAddSongs
+	m.Handlers[EventSetPreview] = SetPreviewMode
 }
 
 func (m *Manager) AddClient(client *Client) {
@@ -66,11 +192,15 @@ func (m *Manager) AddClient(client *Client) {
 
 	if m.Rooms[client.RoomID] == nil {
 		room := &models.Room{}
-		room.GetRoomById(client.RoomID)
+		if err := room.GetRoomById(client.RoomID); err != nil {
+			client.Logger.Warn("could not load room", "error", err)
+		}
 		m.Rooms[client.RoomID] = NewRoomData(room)
+		metrics.ActiveRooms.Set(float64(len(m.Rooms)))
 	}
 
 	m.Rooms[client.RoomID].Clients[client] = true
+	metrics.WebsocketConnections.Inc()
 }
 
 func (m *Manager) RemoveClient(client *Client) {
@@ -79,17 +209,44 @@ func (m *Manager) RemoveClient(client *Client) {
 	room := m.Rooms[client.RoomID]
 
 	if _, ok := room.Clients[client]; ok {
+		client.leave()
+		room.dropPendingSongs(client)
 		client.Connection.Close()
 		delete(m.Rooms[client.RoomID].Clients, client)
+		metrics.WebsocketConnections.Dec()
+		if client.Preview.Load() {
+			room.sendPreviewListeners()
+		}
 	}
 
 }
 
+// DeleteRoom drops a room and disconnects everyone still in it. Their read
+// loops find the room gone and skip RemoveClient, so they are uncounted here.
+func (m *Manager) DeleteRoom(roomID string) {
+	m.Lock()
+	defer m.Unlock()
+	if room, ok := m.Rooms[roomID]; ok {
+		for client := range room.Clients {
+			client.leave()
+			client.Connection.Close()
+			metrics.WebsocketConnections.Dec()
+		}
+	}
+	delete(m.Rooms, roomID)
+	metrics.ActiveRooms.Set(float64(len(m.Rooms)))
+}
+
 func (m *Manager) ServeWs() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		log.Println("New Connection")
-
 		roomId := c.Param("id")
+		logger := logging.FromContext(c.Request.Context())
+
+		ticket, err := services.RedeemRoomTicket(c.Query("ticket"), roomId)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Ticket not valid", "error": err.Error()})
+			return
+		}
 
 		conn, err := websocketUpgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
@@ -97,9 +254,7 @@ func (m *Manager) ServeWs() gin.HandlerFunc {
 			return
 		}
 
-		log.Println("USER ID IS HERE: ", c.GetInt64("userId"))
-
-		client := NewClient(conn, m, roomId, c.GetInt64("userId"))
+		client := NewClient(conn, m, roomId, ticket.UserID, ticket.SessionID, logger)
 		m.AddClient(client)
 
 		go client.ReadMessages()
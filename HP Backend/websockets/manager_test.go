@@ -0,0 +1,151 @@
+package websockets
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"houseparty.com/metrics"
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+func newTestClient(room *RoomData, userId int64, buffer int) *Client {
+	client := &Client{
+		ID:     "conn",
+		User:   &models.User{Id: userId},
+		RoomID: room.ID,
+		Egress: make(chan Event, buffer),
+		Logger: slog.Default(),
+	}
+	client.left, client.leave = context.WithCancel(context.Background())
+	client.spotifyConnected.Store(client.User.SpotifyConnected)
+	room.Clients[client] = true
+	return client
+}
+
+func TestNotifySpotifyRevokedDoesNotWaitForSlowClients(t *testing.T) {
+	room := newTestRoom()
+	manager := newTestManager(room)
+
+	stuck := newTestClient(room, 2, 0)
+	listening := newTestClient(room, 3, 1)
+
+	done := make(chan struct{})
+	go func() {
+		manager.NotifySpotifyRevoked(room.HostID)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("NotifySpotifyRevoked blocked on a client that is not reading")
+	}
+
+	if len(listening.Egress) != 1 {
+		t.Error("client with room in its buffer did not get the event")
+	}
+	if len(stuck.Egress) != 0 {
+		t.Error("event was queued for a client without buffer space")
+	}
+
+	// The manager lock is free again for other room operations.
+	if !manager.TryLock() {
+		t.Fatal("manager is still locked")
+	}
+	manager.Unlock()
+}
+
+func TestNotifySpotifyRevokedStopsHandingOutTokens(t *testing.T) {
+	room := newTestRoom()
+	room.PlaybackMode = models.PlaybackListeners
+	room.MusicProvider = services.NewFakeProvider()
+	manager := newTestManager(room)
+
+	listener := newTestClient(room, 2, 1)
+	listener.spotifyConnected.Store(true)
+	if room.tokenFor(context.Background(), listener) == "" {
+		t.Fatal("connected listener got no token")
+	}
+
+	// The playback goroutine keeps handing out tokens while the revoke lands.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			room.tokenFor(context.Background(), listener)
+		}
+	}()
+	manager.NotifySpotifyRevoked(listener.User.Id)
+	<-done
+
+	if event := <-listener.Egress; event.Type != SpotifyDisconnected {
+		t.Errorf("event = %s, want %s", event.Type, SpotifyDisconnected)
+	}
+	if token := room.tokenFor(context.Background(), listener); token != "" {
+		t.Errorf("listener whose Spotify was revoked still got token %q", token)
+	}
+}
+
+// connect gives client a real websocket connection and returns the other
+// end, which sees the connection close.
+func connect(t *testing.T, client *Client) *websocket.Conn {
+	t.Helper()
+	accepted := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		accepted <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	remote, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { remote.Close() })
+	client.Connection = <-accepted
+	return remote
+}
+
+func TestDeleteRoomDisconnectsAndUncountsClients(t *testing.T) {
+	room := newTestRoom()
+	manager := newTestManager(room)
+
+	var remotes []*websocket.Conn
+	for userId := range int64(3) {
+		client := newTestClient(room, userId+1, 1)
+		remotes = append(remotes, connect(t, client))
+		metrics.WebsocketConnections.Inc()
+	}
+	before := testutil.ToFloat64(metrics.WebsocketConnections)
+
+	manager.DeleteRoom(room.ID)
+
+	if after := testutil.ToFloat64(metrics.WebsocketConnections); after != before-3 {
+		t.Errorf("connections gauge = %v after deleting the room, want %v", after, before-3)
+	}
+	if _, ok := manager.Rooms[room.ID]; ok {
+		t.Error("room was not deleted")
+	}
+	for i, remote := range remotes {
+		remote.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err := remote.ReadMessage()
+		var netErr net.Error
+		if err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+			t.Errorf("client %d is still connected: %v", i, err)
+		}
+	}
+}
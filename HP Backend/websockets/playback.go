@@ -0,0 +1,60 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+
+	"houseparty.com/models"
+)
+
+// tokenFor returns the access token a recipient is allowed to see. The host's
+// token only ever goes to the host; in listeners mode everyone else gets
+// their own linked token, and guests without one get metadata only. Speaker
+// mode is driven by the server, so nobody gets a token.
+func (r *RoomData) tokenFor(ctx context.Context, client *Client) string {
+	var userId int64
+
+	switch r.PlaybackMode {
+	case models.PlaybackSpeaker:
+		return ""
+	case models.PlaybackListeners:
+		if client.User.Id != r.HostID && !client.spotifyConnected.Load() {
+			return ""
+		}
+		userId = client.User.Id
+	default:
+		if client.User.Id != r.HostID {
+			return ""
+		}
+		userId = r.HostID
+	}
+
+	token, err := r.MusicProvider.AccessToken(ctx, userId)
+	if err != nil {
+		client.Logger.Warn("could not get playback token", "playback_mode", r.PlaybackMode, "error", err)
+		return ""
+	}
+	return token
+}
+
+// SendPersonalisedEvent builds the payload about song separately for every
+// client so each recipient only receives the token and preview mode chosen
+// for them.
+func (r *RoomData) SendPersonalisedEvent(ctx context.Context, eventType string, song *models.Song, build func(apiToken string, preview bool) any) {
+	previewChanged := false
+	for client := range r.Clients {
+		apiToken, preview, changed := r.playbackFor(ctx, client, song)
+		previewChanged = previewChanged || changed
+
+		payload, err := json.Marshal(build(apiToken, preview))
+		if err != nil {
+			client.Logger.Error("failed to marshal personalised event", "type", eventType, "error", err)
+			continue
+		}
+		client.Egress <- Event{Type: eventType, Payload: payload}
+	}
+
+	if previewChanged {
+		r.sendPreviewListeners()
+	}
+}
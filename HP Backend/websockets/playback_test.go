@@ -0,0 +1,59 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+func TestSongEventsCarryOnlyTheRecipientsToken(t *testing.T) {
+	tests := []struct {
+		mode string
+		// want holds the owner of the token each recipient gets, or 0 for none.
+		want map[string]int64
+	}{
+		{mode: models.PlaybackHost, want: map[string]int64{"host": 1}},
+		{mode: models.PlaybackListeners, want: map[string]int64{"host": 1, "listener": 2}},
+		{mode: models.PlaybackSpeaker, want: map[string]int64{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			room := newTestRoom()
+			room.PlaybackMode = test.mode
+			room.MusicProvider = services.NewFakeProvider()
+
+			recipients := map[string]*Client{
+				"host":     newTestClient(room, room.HostID, 1),
+				"listener": newTestClient(room, 2, 1),
+				"guest":    newTestClient(room, 3, 1),
+			}
+			recipients["listener"].spotifyConnected.Store(true)
+
+			song := &models.Song{Id: "song"}
+			room.SendPersonalisedEvent(context.Background(), SetAndPlaySong, song, func(apiToken string, preview bool) any {
+				return SetAndPlayCurrentSong{ApiToken: apiToken, Song: song, Preview: preview}
+			})
+
+			for name, client := range recipients {
+				var event SetAndPlayCurrentSong
+				if err := json.Unmarshal((<-client.Egress).Payload, &event); err != nil {
+					t.Fatal(err)
+				}
+
+				owner, ok := test.want[name]
+				switch {
+				case !ok && event.ApiToken != "":
+					t.Errorf("%s got token %q, want none", name, event.ApiToken)
+				case ok && !strings.HasPrefix(event.ApiToken, fmt.Sprintf("fake-token-%d-", owner)):
+					t.Errorf("%s got token %q, want one of user %d", name, event.ApiToken, owner)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"houseparty.com/models"
+)
+
+type SetPreviewModeEvent struct {
+	Enabled bool `json:"enabled"`
+}
+type PreviewModeEvent struct {
+	Preview  bool   `json:"preview"`
+	ApiToken string `json:"api_token"`
+}
+type PreviewListenersEvent struct {
+	Listeners []string `json:"listeners"`
+}
+
+// previewFor reports whether a listener gets the 30 second preview clip of
+// song instead of the full track. Rooms have to opt in, only rooms where
+// every listener plays Spotify themselves need it, and the song has to have a
+// clip. The host always streams the full track. Listeners fall back when they
+// have no token of their own or ask for it because their account cannot
+// stream, such as Spotify Free.
+func (r *RoomData) previewFor(client *Client, song *models.Song, apiToken string) bool {
+	if !r.PreviewClips || r.PlaybackMode != models.PlaybackListeners || client.User.Id == r.HostID {
+		return false
+	}
+	if song == nil || !strings.HasPrefix(song.URI, "spotify:track:") || song.PreviewURL == "" {
+		return false
+	}
+	return apiToken == "" || client.wantsPreview.Load()
+}
+
+// playbackFor returns the token and preview mode for a recipient of song and
+// records the mode on the client. It reports whether the mode changed.
+func (r *RoomData) playbackFor(ctx context.Context, client *Client, song *models.Song) (string, bool, bool) {
+	token := r.tokenFor(ctx, client)
+	preview := r.previewFor(client, song, token)
+	if preview {
+		token = ""
+	}
+
+	changed := client.Preview.Swap(preview) != preview
+	return token, preview, changed
+}
+
+func (r *RoomData) previewListeners() []string {
+	listeners := []string{}
+	for client := range r.Clients {
+		if client.Preview.Load() {
+			listeners = append(listeners, client.User.Username)
+		}
+	}
+	return listeners
+}
+
+func (r *RoomData) sendPreviewListeners() {
+	r.sendToHost(PreviewListeners, PreviewListenersEvent{Listeners: r.previewListeners()})
+}
+
+// SetPreviewMode lets a listener switch between full tracks and preview clips,
+// for example after the Spotify player refused their account.
+func SetPreviewMode(ctx context.Context, event Event, c *Client) error {
+	var previewEvent SetPreviewModeEvent
+	if ok, err := readPayload(c, event, &previewEvent); !ok {
+		return err
+	}
+
+	room := c.Manager.Rooms[c.RoomID]
+	if !room.PreviewClips {
+		return sendError(c, "This room does not offer preview clips.")
+	}
+
+	c.wantsPreview.Store(previewEvent.Enabled)
+	currentSong, _ := room.nowPlaying()
+	token, preview, changed := room.playbackFor(ctx, c, currentSong)
+	if changed {
+		c.Logger.Info("listener preview mode changed", "preview", preview)
+		room.sendPreviewListeners()
+	}
+
+	payload, err := json.Marshal(PreviewModeEvent{Preview: preview, ApiToken: token})
+	if err != nil {
+		return err
+	}
+
+	c.Egress <- Event{Type: PreviewMode, Payload: payload}
+	return nil
+}
@@ -0,0 +1,127 @@
+package websockets
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+var clipSong = &models.Song{Id: "clip", URI: "spotify:track:clip", PreviewURL: "https://p.scdn.co/mp3-preview/clip"}
+
+func TestPreviewModeIsSafeAcrossGoroutines(t *testing.T) {
+	room := newTestRoom()
+	room.PreviewClips = true
+	room.PlaybackMode = models.PlaybackListeners
+	room.MusicProvider = services.NewFakeProvider()
+	listener := newTestClient(room, 2, 0)
+	listener.User.Username = "listener"
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-listener.Egress:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	const rounds = 100
+	var senders sync.WaitGroup
+	senders.Add(2)
+	// The room's playback goroutine sends songs while the listener's read
+	// loop switches preview mode.
+	go func() {
+		defer senders.Done()
+		for range rounds {
+			room.SendPersonalisedEvent(context.Background(), SetAndPlaySong, clipSong, func(apiToken string, preview bool) any {
+				return SetAndPlayCurrentSong{ApiToken: apiToken, Preview: preview}
+			})
+		}
+	}()
+	go func() {
+		defer senders.Done()
+		for i := range rounds {
+			listener.wantsPreview.Store(i%2 == 0)
+			room.playbackFor(context.Background(), listener, clipSong)
+		}
+	}()
+	senders.Wait()
+	close(done)
+	wg.Wait()
+
+	// The listener has no token of their own, so they end up on previews.
+	if !listener.Preview.Load() {
+		t.Error("listener without a token is not on preview clips")
+	}
+	if listeners := room.previewListeners(); len(listeners) != 1 || listeners[0] != "listener" {
+		t.Errorf("preview listeners = %v, want [listener]", listeners)
+	}
+}
+
+func TestPreviewFor(t *testing.T) {
+	localSong := &models.Song{Id: "local-1", PreviewURL: "https://houseparty.test/media/local-1"}
+	noClip := &models.Song{Id: "noclip", URI: "spotify:track:noclip"}
+
+	tests := []struct {
+		name   string
+		mode   string
+		clips  bool
+		host   bool
+		wants  bool
+		song   *models.Song
+		token  string
+		expect bool
+	}{
+		{name: "listener without spotify", mode: models.PlaybackListeners, clips: true, song: clipSong, expect: true},
+		{name: "listener asking for clips", mode: models.PlaybackListeners, clips: true, wants: true, song: clipSong, token: "token", expect: true},
+		{name: "listener streaming", mode: models.PlaybackListeners, clips: true, song: clipSong, token: "token"},
+		{name: "room without clips", mode: models.PlaybackListeners, song: clipSong},
+		{name: "host", mode: models.PlaybackListeners, clips: true, host: true, song: clipSong},
+		{name: "host playback room", mode: models.PlaybackHost, clips: true, song: clipSong},
+		{name: "speaker room", mode: models.PlaybackSpeaker, clips: true, song: clipSong},
+		{name: "local library song", mode: models.PlaybackListeners, clips: true, song: localSong},
+		{name: "song without a clip", mode: models.PlaybackListeners, clips: true, song: noClip},
+		{name: "nothing playing", mode: models.PlaybackListeners, clips: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			room := newTestRoom()
+			room.PlaybackMode = test.mode
+			room.PreviewClips = test.clips
+			client := newTestClient(room, 2, 0)
+			if test.host {
+				client.User.Id = room.HostID
+			}
+			client.wantsPreview.Store(test.wants)
+
+			if got := room.previewFor(client, test.song, test.token); got != test.expect {
+				t.Errorf("previewFor = %v, want %v", got, test.expect)
+			}
+		})
+	}
+}
+
+func TestHostPlaybackListenersAreNotPreviewListeners(t *testing.T) {
+	room := newTestRoom()
+	room.PreviewClips = true
+	room.PlaybackMode = models.PlaybackHost
+	room.MusicProvider = services.NewFakeProvider()
+	listener := newTestClient(room, 2, 1)
+	listener.User.Username = "listener"
+
+	room.SendPersonalisedEvent(context.Background(), SetAndPlaySong, clipSong, func(apiToken string, preview bool) any {
+		return SetAndPlayCurrentSong{ApiToken: apiToken, Song: clipSong, Preview: preview}
+	})
+	if listeners := room.previewListeners(); len(listeners) != 0 {
+		t.Errorf("preview listeners = %v in a room the host plays for", listeners)
+	}
+}
@@ -1,11 +1,15 @@
 package websockets
 
 import (
+	"context"
 	"encoding/json"
+	"log/slog"
+	"sync"
 	"time"
 
-	"houseparty.com/config"
 	"houseparty.com/models"
+	"houseparty.com/services"
+	"houseparty.com/tracing"
 )
 
 type RoomDataList map[string]*RoomData
@@ -18,10 +22,30 @@ type RoomData struct {
 	CurrentSongStartedAt time.Time
 	UserSkipRecord       SkipRecord
 	SkipChan             chan bool
+	MusicProvider        services.MusicProvider
+	Logger               *slog.Logger
+	speakerPaused        bool
+	speakerPositionMs    int
+	speakerLostAt        time.Time
+	speakerRestarted     bool
+	// queueLock guards PlayList, CurrentSong and CurrentSongStartedAt, which
+	// client handlers, imports and the playback goroutine all change.
+	queueLock            sync.Mutex
+	importLock           sync.Mutex
+	importing            bool
+	pendingLock          sync.Mutex
+	pendingSongs         map[string]*pendingSongs
 }
 
 func NewRoomData(room *models.Room) *RoomData {
 	var roomPlaylist []models.Song
+	logger := slog.Default().With("room_id", room.ID)
+
+	provider, err := services.GetProvider(room.Provider)
+	if err != nil {
+		logger.Warn("falling back to default music provider", "provider", room.Provider, "error", err)
+		provider, _ = services.GetProvider(services.DefaultProvider)
+	}
 
 	return &RoomData{
 		Room:           room,
@@ -30,6 +54,9 @@ func NewRoomData(room *models.Room) *RoomData {
 		CurrentSong:    nil,
 		UserSkipRecord: SkipRecord{},
 		SkipChan:       make(chan bool),
+		MusicProvider:  provider,
+		Logger:         logger,
+		pendingSongs:   make(map[string]*pendingSongs),
 	}
 }
 
@@ -39,84 +66,159 @@ func (r *RoomData) SendEventToAllClients(event Event) {
 	}
 }
 
-func (r *RoomData) AddSongToPlaylist(song *models.Song, name string) ([]byte, error) {
-	r.PlayList = append(r.PlayList, *song)
-	response := AddedSongToPlaylist{
-		From: name,
-		Song: song,
+// queueSong checks song against the room's rules and queues it. When nothing
+// is playing the song claims the room instead, so songs queued in the
+// meantime line up behind it, and start tells the caller to play it.
+func (r *RoomData) queueSong(song *models.Song) (reason string, start bool) {
+	r.queueLock.Lock()
+	defer r.queueLock.Unlock()
+
+	if reason := r.songRuleViolation(song); reason != "" {
+		return reason, false
 	}
+	if r.CurrentSong == nil && len(r.PlayList) == 0 {
+		r.CurrentSong = song
+		return "", true
+	}
+	r.PlayList = append(r.PlayList, *song)
+	return "", false
+}
 
-	payload, err := json.Marshal(response)
-	if err != nil {
-		return nil, err
+// nextSong moves the first queued song to CurrentSong and returns it, or
+// clears CurrentSong and returns nil when the queue is empty.
+func (r *RoomData) nextSong() *models.Song {
+	r.queueLock.Lock()
+	defer r.queueLock.Unlock()
+
+	if len(r.PlayList) == 0 {
+		r.CurrentSong = nil
+		return nil
 	}
+	next := r.PlayList[0]
+	r.PlayList = r.PlayList[1:]
+	r.CurrentSong = &next
+	return &next
+}
 
-	return payload, nil
+// nowPlaying returns the current song, if any, and when it started.
+func (r *RoomData) nowPlaying() (*models.Song, time.Time) {
+	r.queueLock.Lock()
+	defer r.queueLock.Unlock()
+	return r.CurrentSong, r.CurrentSongStartedAt
 }
 
-func (r *RoomData) PrepareSongToPlay(song *models.Song) error {
-	tokenObject, err := config.GetSpotifyTokenObject(r.HostID)
+func (r *RoomData) setSongStartedAt(startedAt time.Time) {
+	r.queueLock.Lock()
+	r.CurrentSongStartedAt = startedAt
+	r.queueLock.Unlock()
+}
 
-	if err != nil {
-		return err
-	}
+type playingSong struct {
+	song    *models.Song
+	history models.HistoryEntry
+	timer   *time.Timer
+	poll    *time.Ticker
+}
 
-	response := SetAndPlayCurrentSong{
-		ApiToken: tokenObject.AccessToken,
-		Song:     song,
+// PlaySong plays song and then the rest of the queue until it runs out.
+func (r *RoomData) PlaySong(ctx context.Context, song *models.Song) {
+	playing := r.startSong(ctx, song)
+	for {
+		r.waitForSongEnd(playing)
+
+		// The span covers picking and starting the next song, not the time
+		// it plays for.
+		ctx, span := tracing.Tracer.Start(context.Background(), "room.NextSong")
+		next := r.nextSong()
+		if next == nil {
+			span.End()
+			r.SendEventToAllClients(Event{Type: FinalSongEnded, Payload: nil})
+			return
+		}
+		playing = r.startSong(ctx, next)
+		span.End()
 	}
+}
 
-	payload, err := json.Marshal(response)
-	if err != nil {
-		return err
+func (r *RoomData) startSong(ctx context.Context, song *models.Song) *playingSong {
+	r.Logger.Info("playing song", "song_id", song.Id, "duration_ms", song.DurationMs)
+	playing := &playingSong{song: song, timer: time.NewTimer(time.Duration(song.DurationMs) * time.Millisecond)}
+
+	if r.PlaybackMode == models.PlaybackSpeaker {
+		r.speakerPaused = false
+		r.speakerLostAt = time.Time{}
+		r.speakerRestarted = false
+		if err := r.startSpeakerPlayback(ctx, song, 0); err != nil {
+			// Polling an idle speaker would read as a pause and hold the room
+			// forever, so the song runs on the timer alone and the queue keeps
+			// moving.
+			r.Logger.Error("could not start speaker playback", "song_id", song.Id, "device_id", r.DeviceID, "error", err)
+			r.sendToAll(PlaybackFailed, MessageEvent{Message: "Could not start playback on the selected speaker."})
+		} else {
+			playing.poll = time.NewTicker(speakerPollInterval)
+		}
 	}
 
-	if r.CurrentSong == nil && len(r.PlayList) == 0 {
-		go r.PlaySong(song, payload)
-	} else {
-		r.PlaySong(song, payload)
-	}
+	r.SendPersonalisedEvent(ctx, SetAndPlaySong, song, func(apiToken string, preview bool) any {
+		return SetAndPlayCurrentSong{ApiToken: apiToken, Song: song, Preview: preview}
+	})
+	startedAt := time.Now()
+	r.setSongStartedAt(startedAt)
 
-	return nil
+	playing.history = models.HistoryEntry{RoomID: r.ID, Song: *song, PlayedAt: startedAt}
+	if err := playing.history.Save(); err != nil {
+		r.Logger.Error("could not record played song", "song_id", song.Id, "error", err)
+	}
+	return playing
 }
 
-func (r *RoomData) PlaySong(song *models.Song, payload []byte) {
-	r.CurrentSong = song
-	timer := time.NewTimer(time.Duration(song.DurationMs) * time.Millisecond)
+func (r *RoomData) waitForSongEnd(playing *playingSong) {
+	var poll <-chan time.Time
+	if playing.poll != nil {
+		defer playing.poll.Stop()
+		poll = playing.poll.C
+	}
 
-	event := Event{
-		Type:    SetAndPlaySong,
-		Payload: payload,
+	song := playing.song
+	for {
+		select {
+		case <-playing.timer.C:
+			return
+		case <-r.SkipChan:
+			playing.timer.Stop()
+			if err := playing.history.MarkSkipped(); err != nil {
+				r.Logger.Error("could not record skipped song", "song_id", song.Id, "error", err)
+			}
+			return
+		case <-poll:
+			if r.checkSpeakerPlayback(context.Background(), song, playing.timer) {
+				r.Logger.Info("song changed outside the room", "song_id", song.Id)
+				playing.timer.Stop()
+				return
+			}
+		}
 	}
+}
 
-	r.SendEventToAllClients(event)
-	r.CurrentSongStartedAt = time.Now()
-
-	select {
-	case <-timer.C:
-		r.HandleSongSkip()
-	case <-r.SkipChan:
-		timer.Stop()
-		r.HandleSongSkip()
+func (r *RoomData) sendToAll(eventType string, message any) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return
 	}
 
+	r.SendEventToAllClients(Event{Type: eventType, Payload: payload})
 }
 
-func (r *RoomData) HandleSongSkip() {
-	if len(r.PlayList) > 0 {
-		nextSong := r.PlayList[0]
-		r.PlayList = r.PlayList[1:]
-
-		r.PrepareSongToPlay(&nextSong)
-	} else {
-		r.CurrentSong = nil
+func (r *RoomData) sendToHost(eventType string, message any) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
 
-		event := Event{
-			Type:    FinalSongEnded,
-			Payload: nil,
+	for client := range r.Clients {
+		if client.User.Id == r.HostID {
+			client.Egress <- Event{Type: eventType, Payload: payload}
 		}
-
-		r.SendEventToAllClients(event)
 	}
 }
 
@@ -0,0 +1,133 @@
+package websockets
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"houseparty.com/models"
+	"houseparty.com/storage"
+	"houseparty.com/tracing"
+)
+
+func newTestRoom() *RoomData {
+	return &RoomData{
+		Room:         &models.Room{ID: "room", HostID: 1},
+		Clients:      make(ClientList),
+		SkipChan:     make(chan bool),
+		Logger:       slog.Default(),
+		pendingSongs: make(map[string]*pendingSongs),
+	}
+}
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	storage.OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	t.Cleanup(func() { storage.DB.Close() })
+}
+
+func TestQueueSongIsSafeAlongsidePlayback(t *testing.T) {
+	room := newTestRoom()
+
+	const songs = 200
+	var wg sync.WaitGroup
+	starts := make(chan string, songs)
+	for i := range songs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			song := &models.Song{Id: fmt.Sprint("song-", i)}
+			if reason, start := room.queueSong(song); reason != "" {
+				t.Errorf("%s was not queued: %s", song.Id, reason)
+			} else if start {
+				starts <- song.Id
+			}
+		}()
+	}
+
+	// The playback goroutine moves through the queue at the same time.
+	played := make(map[string]bool)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range songs / 2 {
+			if song := room.nextSong(); song != nil {
+				played[song.Id] = true
+			}
+		}
+	}()
+	wg.Wait()
+	<-done
+	close(starts)
+
+	for id := range starts {
+		played[id] = true
+	}
+	current, _ := room.nowPlaying()
+	if current != nil {
+		played[current.Id] = true
+	}
+	for _, song := range room.PlayList {
+		if played[song.Id] {
+			t.Errorf("%s is both queued and played", song.Id)
+		}
+		played[song.Id] = true
+	}
+	if len(played) != songs {
+		t.Errorf("%d songs accounted for, want %d", len(played), songs)
+	}
+}
+
+func TestQueueSongRejectsDuplicates(t *testing.T) {
+	room := newTestRoom()
+
+	if _, start := room.queueSong(&models.Song{Id: "a"}); !start {
+		t.Fatal("first song should start playing")
+	}
+	if _, start := room.queueSong(&models.Song{Id: "b"}); start {
+		t.Fatal("second song should be queued")
+	}
+	for _, id := range []string{"a", "b"} {
+		if reason, _ := room.queueSong(&models.Song{Id: id}); reason != SkipDuplicate {
+			t.Errorf("queueing %s again: reason = %q, want %q", id, reason, SkipDuplicate)
+		}
+	}
+}
+
+func TestNextSongSpanEndsOnceSongStarts(t *testing.T) {
+	openTestDB(t)
+	recorder := tracetest.NewSpanRecorder()
+	previous := tracing.Tracer
+	tracing.Tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	t.Cleanup(func() { tracing.Tracer = previous })
+
+	room := newTestRoom()
+	const songLength = 300 * time.Millisecond
+	first := &models.Song{Id: "a", DurationMs: int(songLength.Milliseconds())}
+	room.queueSong(first)
+	room.queueSong(&models.Song{Id: "b", DurationMs: int(songLength.Milliseconds())})
+
+	room.PlaySong(context.Background(), first)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans ended, want one for each time the queue moved on", len(spans))
+	}
+	for _, span := range spans {
+		if span.Name() != "room.NextSong" {
+			t.Errorf("span %q, want room.NextSong", span.Name())
+		}
+		if length := span.EndTime().Sub(span.StartTime()); length >= songLength {
+			t.Errorf("room.NextSong lasted %v, the length of a whole song", length)
+		}
+	}
+	if current, _ := room.nowPlaying(); current != nil {
+		t.Errorf("%s is still playing after the queue ran out", current.Id)
+	}
+}
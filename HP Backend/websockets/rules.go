@@ -0,0 +1,41 @@
+package websockets
+
+import "houseparty.com/models"
+
+const (
+	SkipDuplicate   = "already in the queue"
+	SkipExplicit    = "explicit songs are blocked in this room"
+	SkipUnavailable = "not available to play"
+	SkipQueueFull   = "the queue is full"
+)
+
+const maxQueueLength = 500
+
+// songRuleViolation checks a song against the room's content rules and
+// returns why it cannot be queued, or an empty string when it can. The caller
+// holds queueLock.
+func (r *RoomData) songRuleViolation(song *models.Song) string {
+	switch {
+	case song.Id == "":
+		return SkipUnavailable
+	case song.Explicit && r.BlockExplicit:
+		return SkipExplicit
+	case r.isQueued(song.Id):
+		return SkipDuplicate
+	case len(r.PlayList) >= maxQueueLength:
+		return SkipQueueFull
+	}
+	return ""
+}
+
+func (r *RoomData) isQueued(songId string) bool {
+	if r.CurrentSong != nil && r.CurrentSong.Id == songId {
+		return true
+	}
+	for _, song := range r.PlayList {
+		if song.Id == songId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"houseparty.com/models"
+	"houseparty.com/services"
+	"houseparty.com/validation"
+)
+
+const (
+	speakerPollInterval = 5 * time.Second
+	speakerStartGrace   = 4 * time.Second
+	// speakerLostGrace is how long a missing or idle device gets to pick
+	// the song up again before the room starts it on the device once more.
+	speakerLostGrace = 15 * time.Second
+)
+
+type DevicesEvent struct {
+	Devices          []models.Device `json:"devices"`
+	SelectedDeviceID string          `json:"selected_device_id"`
+}
+
+type SelectDeviceEvent struct {
+	DeviceID string `json:"device_id" binding:"required,max=100"`
+}
+
+type MessageEvent struct {
+	Message string            `json:"message"`
+	Fields  validation.Errors `json:"fields,omitempty"`
+}
+
+var errNoPlaybackControl = errors.New("this room's music provider cannot control playback")
+
+func ListDevices(ctx context.Context, event Event, c *Client) error {
+	room := c.Manager.Rooms[c.RoomID]
+	if c.User.Id != room.HostID {
+		return sendError(c, "Only the host can list playback devices.")
+	}
+
+	controller, ok := services.PlaybackControllerFor(room.MusicProvider)
+	if !ok {
+		return sendError(c, errNoPlaybackControl.Error())
+	}
+
+	devices, err := controller.ListDevices(ctx, room.HostID)
+	if err != nil {
+		c.Logger.Warn("could not list playback devices", "error", err)
+		return sendError(c, "Could not load your Spotify devices.")
+	}
+
+	payload, err := json.Marshal(DevicesEvent{Devices: devices, SelectedDeviceID: room.DeviceID})
+	if err != nil {
+		return err
+	}
+
+	c.Egress <- Event{Type: DevicesList, Payload: payload}
+	return nil
+}
+
+func SelectDevice(ctx context.Context, event Event, c *Client) error {
+	room := c.Manager.Rooms[c.RoomID]
+	if c.User.Id != room.HostID {
+		return sendError(c, "Only the host can choose the playback device.")
+	}
+
+	var selectEvent SelectDeviceEvent
+	if ok, err := readPayload(c, event, &selectEvent); !ok {
+		return err
+	}
+
+	if err := room.SetDevice(selectEvent.DeviceID); err != nil {
+		return err
+	}
+	room.Logger.Info("speaker device selected", "device_id", selectEvent.DeviceID)
+
+	currentSong, startedAt := room.nowPlaying()
+	if room.PlaybackMode == models.PlaybackSpeaker && currentSong != nil {
+		position := int(time.Since(startedAt).Milliseconds())
+		if err := room.startSpeakerPlayback(ctx, currentSong, position); err != nil {
+			c.Logger.Warn("could not transfer playback", "device_id", selectEvent.DeviceID, "error", err)
+			return sendError(c, "Could not start playback on that device.")
+		}
+	}
+
+	return ListDevices(ctx, event, c)
+}
+
+func sendError(c *Client, message string) error {
+	payload, err := json.Marshal(MessageEvent{Message: message})
+	if err != nil {
+		return err
+	}
+
+	c.send(Event{Type: EventError, Payload: payload})
+	return nil
+}
+
+func sendInvalid(c *Client, fields validation.Errors) error {
+	payload, err := json.Marshal(MessageEvent{Message: "Invalid request: " + fields.Error() + ".", Fields: fields})
+	if err != nil {
+		return err
+	}
+
+	c.send(Event{Type: EventError, Payload: payload})
+	return nil
+}
+
+func (r *RoomData) startSpeakerPlayback(ctx context.Context, song *models.Song, positionMs int) error {
+	controller, ok := services.PlaybackControllerFor(r.MusicProvider)
+	if !ok {
+		return errNoPlaybackControl
+	}
+	return controller.StartPlayback(ctx, r.HostID, r.DeviceID, song, positionMs)
+}
+
+// checkSpeakerPlayback compares what the host's device is doing with what the
+// room thinks is playing. Pauses made outside the app stop the song timer
+// until playback resumes; it returns true when the track was changed
+// externally, or the device is gone for good, so the room should move on.
+func (r *RoomData) checkSpeakerPlayback(ctx context.Context, song *models.Song, timer *time.Timer) bool {
+	controller, ok := services.PlaybackControllerFor(r.MusicProvider)
+	if !ok {
+		return false
+	}
+
+	state, err := controller.PlaybackState(ctx, r.HostID)
+	if err != nil {
+		r.Logger.Debug("could not poll speaker playback", "error", err)
+		return false
+	}
+
+	_, startedAt := r.nowPlaying()
+	elapsed := time.Since(startedAt)
+	if state == nil || !state.IsPlaying {
+		// Spotify reports no playback at all, or an idle player, when the
+		// device drops off or the song ran out just before this poll. Only a
+		// song still loaded part way through was paused by someone.
+		if state != nil && state.IsPlayingSong(song) && state.ProgressMs > 0 {
+			r.pauseSpeakerSong(timer, state.ProgressMs)
+			return false
+		}
+		if !r.speakerPaused && elapsed >= time.Duration(song.DurationMs)*time.Millisecond-speakerPollInterval {
+			return true
+		}
+		return r.speakerIdle(ctx, song, timer, int(elapsed.Milliseconds()))
+	}
+
+	if !state.IsPlayingSong(song) {
+		return elapsed > speakerStartGrace
+	}
+
+	if r.speakerPaused {
+		r.speakerPaused = false
+		r.speakerLostAt = time.Time{}
+		r.speakerRestarted = false
+		remaining := time.Duration(song.DurationMs-state.ProgressMs) * time.Millisecond
+		timer.Reset(remaining)
+		r.setSongStartedAt(time.Now().Add(-time.Duration(state.ProgressMs) * time.Millisecond))
+		r.SendEventToAllClients(Event{Type: PlaybackResumed, Payload: nil})
+	}
+	return false
+}
+
+func (r *RoomData) pauseSpeakerSong(timer *time.Timer, positionMs int) {
+	if r.speakerPaused {
+		return
+	}
+	r.speakerPaused = true
+	r.speakerPositionMs = positionMs
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	r.SendEventToAllClients(Event{Type: PlaybackPaused, Payload: nil})
+}
+
+// speakerIdle holds the song while the host's device is missing or idle and
+// tells the host. After speakerLostGrace the song is started on the device
+// again, and if the device is still gone after another grace period the room
+// moves on instead of waiting for someone to resume it.
+func (r *RoomData) speakerIdle(ctx context.Context, song *models.Song, timer *time.Timer, positionMs int) bool {
+	if r.speakerPaused && r.speakerLostAt.IsZero() {
+		// The host paused the song before the device went quiet.
+		return false
+	}
+
+	if r.speakerLostAt.IsZero() {
+		r.pauseSpeakerSong(timer, positionMs)
+		r.speakerLostAt = time.Now()
+		r.Logger.Warn("speaker device stopped playing", "song_id", song.Id, "device_id", r.DeviceID)
+		r.sendToHost(SpeakerLost, MessageEvent{Message: "Your speaker stopped playing. Open Spotify on it or choose another device."})
+		return false
+	}
+	if time.Since(r.speakerLostAt) < speakerLostGrace {
+		return false
+	}
+	if r.speakerRestarted {
+		r.Logger.Warn("speaker device did not come back, moving on", "song_id", song.Id, "device_id", r.DeviceID)
+		return true
+	}
+
+	r.speakerRestarted = true
+	r.speakerLostAt = time.Now()
+	if err := r.startSpeakerPlayback(ctx, song, r.speakerPositionMs); err != nil {
+		r.Logger.Warn("could not restart speaker playback", "song_id", song.Id, "device_id", r.DeviceID, "error", err)
+	}
+	return false
+}
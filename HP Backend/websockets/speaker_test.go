@@ -0,0 +1,185 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"houseparty.com/models"
+	"houseparty.com/services"
+)
+
+// fakeSpeaker adds speaker playback to the fake provider.
+type fakeSpeaker struct {
+	*services.FakeProvider
+	startErr error
+	state    *models.PlaybackState
+	// started records the position of every StartPlayback call.
+	started []int
+}
+
+func (s *fakeSpeaker) ListDevices(ctx context.Context, userId int64) ([]models.Device, error) {
+	return nil, nil
+}
+
+func (s *fakeSpeaker) StartPlayback(ctx context.Context, userId int64, deviceId string, song *models.Song, positionMs int) error {
+	s.started = append(s.started, positionMs)
+	return s.startErr
+}
+
+func (s *fakeSpeaker) PausePlayback(ctx context.Context, userId int64, deviceId string) error {
+	return nil
+}
+
+func (s *fakeSpeaker) PlaybackState(ctx context.Context, userId int64) (*models.PlaybackState, error) {
+	return s.state, nil
+}
+
+func newSpeakerRoom(speaker *fakeSpeaker) *RoomData {
+	room := newTestRoom()
+	room.PlaybackMode = models.PlaybackSpeaker
+	room.DeviceID = "speaker"
+	room.MusicProvider = speaker
+	return room
+}
+
+func TestCheckSpeakerPlaybackFollowsRelinkedTracks(t *testing.T) {
+	song := &models.Song{Id: "requested", URI: "spotify:track:requested", DurationMs: 60_000}
+	speaker := &fakeSpeaker{FakeProvider: services.NewFakeProvider(), state: &models.PlaybackState{
+		IsPlaying:     true,
+		TrackID:       "market",
+		TrackURI:      "spotify:track:market",
+		LinkedFromID:  "requested",
+		LinkedFromURI: "spotify:track:requested",
+	}}
+	room := newSpeakerRoom(speaker)
+	room.queueSong(song)
+	room.setSongStartedAt(time.Now().Add(-time.Minute))
+
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+	if room.checkSpeakerPlayback(context.Background(), song, timer) {
+		t.Error("relinked track was treated as a song changed outside the room")
+	}
+
+	speaker.state = &models.PlaybackState{IsPlaying: true, TrackID: "other", TrackURI: "spotify:track:other"}
+	if !room.checkSpeakerPlayback(context.Background(), song, timer) {
+		t.Error("another track on the speaker was not noticed")
+	}
+}
+
+func TestPlaySongCarriesOnWhenSpeakerFails(t *testing.T) {
+	openTestDB(t)
+	speaker := &fakeSpeaker{FakeProvider: services.NewFakeProvider(), startErr: errors.New("no active device")}
+	room := newSpeakerRoom(speaker)
+	client := newTestClient(room, 2, 10)
+
+	song := &models.Song{Id: "a", DurationMs: 50}
+	room.queueSong(song)
+	done := make(chan struct{})
+	go func() {
+		room.PlaySong(context.Background(), song)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("room stayed on a song the speaker never started")
+	}
+
+	var failed bool
+	for len(client.Egress) > 0 {
+		event := <-client.Egress
+		if event.Type != PlaybackFailed {
+			continue
+		}
+		failed = true
+		var message MessageEvent
+		if err := json.Unmarshal(event.Payload, &message); err != nil || message.Message == "" {
+			t.Errorf("playback failed event without a message: %s", event.Payload)
+		}
+	}
+	if !failed {
+		t.Error("clients were not told the speaker failed")
+	}
+}
+
+func eventTypes(client *Client) []string {
+	var types []string
+	for len(client.Egress) > 0 {
+		types = append(types, (<-client.Egress).Type)
+	}
+	return types
+}
+
+func TestCheckSpeakerPlaybackWithoutDevice(t *testing.T) {
+	song := &models.Song{Id: "song", URI: "spotify:track:song", DurationMs: 180_000}
+	speaker := &fakeSpeaker{FakeProvider: services.NewFakeProvider()}
+	room := newSpeakerRoom(speaker)
+	host := newTestClient(room, room.HostID, 10)
+	room.queueSong(song)
+	room.setSongStartedAt(time.Now().Add(-time.Minute))
+
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+	if room.checkSpeakerPlayback(context.Background(), song, timer) {
+		t.Fatal("room moved on as soon as the device went quiet")
+	}
+	if got := eventTypes(host); !slices.Equal(got, []string{PlaybackPaused, SpeakerLost}) {
+		t.Errorf("host got %v, want the song paused and the device reported lost", got)
+	}
+
+	// The device is given another go at the song once the grace runs out.
+	room.speakerLostAt = time.Now().Add(-speakerLostGrace)
+	if room.checkSpeakerPlayback(context.Background(), song, timer) {
+		t.Fatal("room moved on without restarting the song")
+	}
+	if len(speaker.started) != 1 || speaker.started[0] < 60_000 {
+		t.Fatalf("playback restarted at %v, want once from about a minute in", speaker.started)
+	}
+
+	// A device that is still gone no longer holds up the room.
+	room.speakerLostAt = time.Now().Add(-speakerLostGrace)
+	if !room.checkSpeakerPlayback(context.Background(), song, timer) {
+		t.Error("room is still waiting for a device that did not come back")
+	}
+}
+
+func TestCheckSpeakerPlaybackTellsEndFromPause(t *testing.T) {
+	song := &models.Song{Id: "song", URI: "spotify:track:song", DurationMs: 180_000}
+	tests := []struct {
+		name    string
+		state   *models.PlaybackState
+		elapsed time.Duration
+		next    bool
+		events  []string
+	}{
+		{name: "song ran out", elapsed: 178 * time.Second, next: true},
+		{name: "player idle at the end", state: &models.PlaybackState{TrackID: "song"}, elapsed: 178 * time.Second, next: true},
+		{name: "paused by the host", state: &models.PlaybackState{TrackID: "song", ProgressMs: 30_000}, elapsed: 30 * time.Second, events: []string{PlaybackPaused}},
+		{name: "paused near the end", state: &models.PlaybackState{TrackID: "song", ProgressMs: 178_000}, elapsed: 178 * time.Second, events: []string{PlaybackPaused}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			speaker := &fakeSpeaker{FakeProvider: services.NewFakeProvider(), state: test.state}
+			room := newSpeakerRoom(speaker)
+			host := newTestClient(room, room.HostID, 10)
+			room.queueSong(song)
+			room.setSongStartedAt(time.Now().Add(-test.elapsed))
+
+			timer := time.NewTimer(time.Minute)
+			defer timer.Stop()
+			if next := room.checkSpeakerPlayback(context.Background(), song, timer); next != test.next {
+				t.Errorf("moved on = %v, want %v", next, test.next)
+			}
+			if got := eventTypes(host); !slices.Equal(got, test.events) {
+				t.Errorf("host got %v, want %v", got, test.events)
+			}
+		})
+	}
+}
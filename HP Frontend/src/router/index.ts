@@ -5,6 +5,8 @@ import { useUserStore } from '@/stores/user';
 import CreateRoomView from '@/views/CreateRoomView.vue'
 import RoomView from '@/views/RoomView.vue';
 import SpotifyCallback from '@/views/SpotifyCallback.vue';
+import VerifyEmailView from '@/views/VerifyEmailView.vue';
+import ResetPasswordView from '@/views/ResetPasswordView.vue';
 
 const router = createRouter({
   history: createWebHistory(import.meta.env.BASE_URL),
@@ -38,6 +40,16 @@ const router = createRouter({
       name: 'spotify-call',
       component: SpotifyCallback,
     },
+    {
+      path: '/verify-email',
+      name: 'verify-email',
+      component: VerifyEmailView,
+    },
+    {
+      path: '/reset-password',
+      name: 'reset-password',
+      component: ResetPasswordView,
+    },
 
   ],
 })
@@ -9,12 +9,21 @@ interface UserCredentials {
 
 interface UserState {
   jwt: string | null;
+  refreshToken: string | null;
   credentials: UserCredentials ;
 }
 
+interface AuthTokens {
+  token: string;
+  refresh_token: string;
+}
+
+const apiBaseUrl = import.meta.env.VITE_API_BASE_URL
+
 export const useUserStore = defineStore('user', {
   state: (): UserState => ({
     jwt: localStorage.getItem('jwt') || null,
+    refreshToken: localStorage.getItem('refresh_token') || null,
     credentials: JSON.parse(localStorage.getItem('credentials') || 'null')
   }),
   getters: {
@@ -27,6 +36,36 @@ export const useUserStore = defineStore('user', {
       this.jwt = jwt;
       localStorage.setItem('jwt', jwt);
     },
+    setTokens(tokens: AuthTokens) {
+      this.setJwt(tokens.token);
+      this.refreshToken = tokens.refresh_token;
+      localStorage.setItem('refresh_token', tokens.refresh_token);
+    },
+    // refresh swaps the refresh token for a new pair. Each refresh token only
+    // works once, so the new one has to be stored straight away.
+    async refresh(): Promise<boolean> {
+      if (!this.refreshToken) return false;
+
+      const response = await fetch(`${apiBaseUrl}/token/refresh`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: this.refreshToken }),
+      });
+      if (!response.ok) {
+        this.clearUser();
+        return false;
+      }
+
+      this.setTokens(await response.json());
+      return true;
+    },
+    async logout() {
+      await fetch(`${apiBaseUrl}/logout`, {
+        method: 'POST',
+        headers: { Authorization: `Bearer ${this.jwt}` },
+      }).catch((error) => console.error('Could not log out:', error));
+      this.clearUser();
+    },
     activateSpotifyConnection() {
       this.credentials.spotify_connected = true
       localStorage.setItem('credentials', JSON.stringify(this.credentials));
@@ -37,7 +76,9 @@ export const useUserStore = defineStore('user', {
     },
     clearUser() {
       this.jwt = null;
+      this.refreshToken = null;
       localStorage.removeItem('jwt');
+      localStorage.removeItem('refresh_token');
       localStorage.removeItem('credentials');
     },
   }
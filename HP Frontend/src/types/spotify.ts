@@ -8,6 +8,8 @@ export interface Song {
   duration_ms: number
   explicit: boolean
   externalUrl: string
+  stream_url?: string
+  preview_url?: string
 }
 interface Image {
   url: string